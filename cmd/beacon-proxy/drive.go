@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/streamingfast/derr"
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/config"
+	"github.com/streamingfast/geth-proxy/json-rpc/jwtauth"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"go.uber.org/zap"
+)
+
+const (
+	// payloadBuildTime is the time given to the execution node to build a payload before it's
+	// retrieved, consensus clients usually wait a few seconds but there are no transactions
+	// on a devnet worth waiting for.
+	payloadBuildTime = 500 * time.Millisecond
+
+	driveCallTimeout = 8 * time.Second
+
+	// withdrawalAmountGwei is the amount of each synthetic withdrawal, a typical partial
+	// withdrawal of consensus rewards.
+	withdrawalAmountGwei = 15_000_000
+)
+
+func init() {
+	rootCmd.AddCommand(DriveCommand)
+
+	DriveCommand.Flags().String("network", "battlefield", "The network driven, one of mainnet, goerli or battlefield, used to pick the Engine API method versions from the fork active at each slot")
+	DriveCommand.Flags().String("endpoint", "http://localhost:8080", "The Engine API endpoint driven, either a proxy started with 'serve' or an execution node")
+	DriveCommand.Flags().String("jwt-secret", "", "Path to the hex encoded JWT secret file used to authenticate against --endpoint, when empty requests are not authenticated")
+	DriveCommand.Flags().String("head-block-hash", "", "The hash of the block the chain is built on, when empty the latest block of --endpoint is used which requires the endpoint to serve eth_getBlockByNumber")
+	DriveCommand.Flags().Duration("slot-duration", 12*time.Second, "The interval at which blocks are produced")
+	DriveCommand.Flags().Uint64("slots", 0, "The number of blocks produced before exiting, produces blocks until interrupted when 0")
+	DriveCommand.Flags().Uint64("finalization-distance", 64, "The number of blocks the finalized block lags behind the head, the safe block is always the head's parent")
+	DriveCommand.Flags().String("fee-recipient", "0x0000000000000000000000000000000000000000", "The suggested fee recipient of the payloads")
+	DriveCommand.Flags().Uint64("withdrawals-per-slot", 4, "The number of synthetic withdrawals included in each payload once Shanghai is active")
+}
+
+var DriveCommand = &cobra.Command{
+	Use:   "drive",
+	Short: "Plays the role of a consensus client, producing a block each slot through the Engine API of a proxy or execution node",
+	RunE:  driveE,
+}
+
+func driveE(cmd *cobra.Command, args []string) error {
+	network := viper.GetString("drive-network")
+	endpoint := viper.GetString("drive-endpoint")
+	jwtSecretPath := viper.GetString("drive-jwt-secret")
+	headBlockHash := viper.GetString("drive-head-block-hash")
+	slotDuration := viper.GetDuration("drive-slot-duration")
+	slots := viper.GetUint64("drive-slots")
+	finalizationDistance := viper.GetUint64("drive-finalization-distance")
+	feeRecipientRaw := viper.GetString("drive-fee-recipient")
+	withdrawalsPerSlot := viper.GetUint64("drive-withdrawals-per-slot")
+
+	chainConfig, err := config.NetworkNameToChainConfig(network)
+	if err != nil {
+		return fmt.Errorf("invalid network: %w", err)
+	}
+
+	feeRecipient, err := eth.NewAddress(feeRecipientRaw)
+	if err != nil {
+		return fmt.Errorf("invalid fee recipient %q: %w", feeRecipientRaw, err)
+	}
+
+	var secret []byte
+	if jwtSecretPath != "" {
+		secret, err = jwtauth.LoadSecret(jwtSecretPath)
+		if err != nil {
+			return fmt.Errorf("loading jwt secret: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-derr.SetupSignalHandler(0 * time.Second):
+			zlog.Info("signal received, stopping driver")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	driver := &driver{
+		client:               upstream.NewClient(endpoint, secret),
+		chainConfig:          chainConfig,
+		feeRecipient:         feeRecipient,
+		withdrawalsPerSlot:   withdrawalsPerSlot,
+		finalizationDistance: finalizationDistance,
+	}
+
+	head, err := driver.resolveHead(ctx, headBlockHash)
+	if err != nil {
+		return err
+	}
+
+	zlog.Info("driving chain", zap.String("endpoint", endpoint), zap.String("network", network), zap.Stringer("head_block_hash", head.Hash), zap.Uint64("head_block_number", uint64(head.Number)), zap.Duration("slot_duration", slotDuration))
+	driver.run(ctx, head, slotDuration, slots)
+
+	return nil
+}
+
+type driveBlock struct {
+	Hash      eth.Hash   `json:"hash"`
+	Number    eth.Uint64 `json:"number"`
+	Timestamp eth.Uint64 `json:"timestamp"`
+}
+
+// driver produces blocks the way a consensus client does: a forkchoice update with payload
+// attributes to start building on the head, the built payload is retrieved, imported back
+// and made the new head with a second forkchoice update.
+type driver struct {
+	client               *upstream.Client
+	chainConfig          *config.ChainConfig
+	feeRecipient         eth.Address
+	withdrawalsPerSlot   uint64
+	finalizationDistance uint64
+
+	// chain holds the blocks produced, the first one being the block the driver started from
+	chain          []*driveBlock
+	slot           uint64
+	withdrawalSeq  uint64
+	validatorIndex uint64
+}
+
+// run produces a block every `slotDuration` on top of `head` until `slots` blocks were
+// produced, or until `ctx` is done when `slots` is 0. A slot failing to produce its block is
+// logged and retried on the next one.
+func (d *driver) run(ctx context.Context, head *driveBlock, slotDuration time.Duration, slots uint64) {
+	d.chain = []*driveBlock{head}
+
+	ticker := time.NewTicker(slotDuration)
+	defer ticker.Stop()
+
+	for produced := uint64(0); slots == 0 || produced < slots; {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		block, err := d.produce(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			zlog.Warn("unable to produce block, retrying next slot", zap.Error(err))
+			continue
+		}
+
+		produced++
+		zlog.Info("produced block", zap.Uint64("block_number", uint64(block.Number)), zap.Stringer("block_hash", block.Hash), zap.Uint64("timestamp", uint64(block.Timestamp)))
+	}
+}
+
+// resolveHead returns the block the chain is built on. When given by hash, only its hash is
+// known, the endpoint doesn't need to serve the `eth` namespace.
+func (d *driver) resolveHead(ctx context.Context, headBlockHash string) (*driveBlock, error) {
+	if headBlockHash != "" {
+		hash, err := eth.NewHash(headBlockHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid head block hash %q: %w", headBlockHash, err)
+		}
+
+		return &driveBlock{Hash: hash}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, driveCallTimeout)
+	defer cancel()
+
+	head := &driveBlock{}
+	if err := d.client.Call(ctx, "eth_getBlockByNumber", []interface{}{"latest", false}, head); err != nil {
+		return nil, fmt.Errorf("fetching latest block, use --head-block-hash if the endpoint doesn't serve it: %w", err)
+	}
+
+	return head, nil
+}
+
+func (d *driver) produce(ctx context.Context) (*driveBlock, error) {
+	d.slot++
+	parent := d.chain[len(d.chain)-1]
+
+	timestamp := uint64(time.Now().Unix())
+	if timestamp <= uint64(parent.Timestamp) {
+		timestamp = uint64(parent.Timestamp) + 1
+	}
+
+	version := 1
+	switch {
+	case d.chainConfig.IsCancunTime(timestamp):
+		version = 3
+	case d.chainConfig.IsShanghaiTime(timestamp):
+		version = 2
+	}
+
+	payloadID, err := d.forkchoiceUpdated(ctx, version, parent.Hash, d.attributes(version, timestamp))
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(payloadBuildTime):
+	}
+
+	block, newPayloadParams, err := d.getPayload(ctx, version, payloadID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &services.PayloadStatusV1Args{}
+	if err := d.call(ctx, fmt.Sprintf("engine_newPayloadV%d", version), newPayloadParams, status); err != nil {
+		return nil, err
+	}
+
+	if status.Status != services.EnginePayloadStatusValid {
+		return nil, fmt.Errorf("payload %s answered %s: %s", block.Hash, status.Status, stringOrEmpty(status.ValidationError))
+	}
+
+	// The block is appended first so it's the head of the chain, its parent being safe. If the
+	// forkchoice update fails, the next slot builds on the block which was imported already.
+	d.chain = append(d.chain, block)
+	if uint64(len(d.chain)) > d.finalizationDistance+1 {
+		d.chain = d.chain[1:]
+	}
+
+	if _, err := d.forkchoiceUpdated(ctx, version, block.Hash, nil); err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// forkchoiceUpdated makes `head`, the last block of the chain, the head with its parent being
+// safe and the oldest block retained finalized. The payload id is returned when `attributes` are provided.
+func (d *driver) forkchoiceUpdated(ctx context.Context, version int, head eth.Hash, attributes interface{}) (*eth.Hex, error) {
+	safe := head
+	if len(d.chain) > 1 {
+		safe = d.chain[len(d.chain)-2].Hash
+	}
+
+	state := &services.ForkchoiceStateV1Args{HeadBlockHash: head, SafeBlockHash: safe, FinalizedBlockHash: d.chain[0].Hash}
+
+	reply := &services.ForkchoiceUpdatedV1Reply{}
+	if err := d.call(ctx, fmt.Sprintf("engine_forkchoiceUpdatedV%d", version), []interface{}{state, attributes}, reply); err != nil {
+		return nil, err
+	}
+
+	if reply.PayloadStatus.Status != services.EnginePayloadStatusValid {
+		return nil, fmt.Errorf("forkchoice updated to %s answered %s: %s", head, reply.PayloadStatus.Status, stringOrEmpty(reply.PayloadStatus.ValidationError))
+	}
+
+	if attributes != nil && reply.PayloadID == nil {
+		return nil, fmt.Errorf("forkchoice updated to %s did not start building a payload", head)
+	}
+
+	return reply.PayloadID, nil
+}
+
+// getPayload retrieves the built payload and returns it along with the params of the
+// `engine_newPayload` call importing it.
+func (d *driver) getPayload(ctx context.Context, version int, payloadID *eth.Hex) (*driveBlock, []interface{}, error) {
+	method := fmt.Sprintf("engine_getPayloadV%d", version)
+	params := []interface{}{payloadID}
+
+	switch version {
+	case 1:
+		payload := &services.ExecutionPayloadV1Args{}
+		if err := d.call(ctx, method, params, payload); err != nil {
+			return nil, nil, err
+		}
+
+		return &driveBlock{Hash: payload.BlockHash, Number: payload.BlockNumber, Timestamp: payload.Timestamp}, []interface{}{payload}, nil
+	case 2:
+		reply := &services.GetPayloadV2Reply{}
+		if err := d.call(ctx, method, params, reply); err != nil {
+			return nil, nil, err
+		}
+
+		payload := &reply.ExecutionPayload
+		return &driveBlock{Hash: payload.BlockHash, Number: payload.BlockNumber, Timestamp: payload.Timestamp}, []interface{}{payload}, nil
+	default:
+		reply := &services.GetPayloadV3Reply{}
+		if err := d.call(ctx, method, params, reply); err != nil {
+			return nil, nil, err
+		}
+
+		// Blobs are not produced, the versioned hashes are derived from the bundle commitments
+		// which are expected to be empty.
+		if len(reply.BlobsBundle.Commitments) > 0 {
+			return nil, nil, fmt.Errorf("payload with blobs is not supported")
+		}
+
+		payload := &reply.ExecutionPayload
+		return &driveBlock{Hash: payload.BlockHash, Number: payload.BlockNumber, Timestamp: payload.Timestamp}, []interface{}{payload, []eth.Hash{}, d.parentBeaconBlockRoot()}, nil
+	}
+}
+
+func (d *driver) attributes(version int, timestamp uint64) interface{} {
+	prevRandao := eth.Hash(crypto.Keccak256(slotBytes("randao", d.slot)))
+
+	switch version {
+	case 1:
+		return &services.PayloadAttributesV1Args{Timestamp: eth.Uint64(timestamp), PrevRandao: prevRandao, SuggestedFeeRecipient: d.feeRecipient}
+	case 2:
+		return &services.PayloadAttributesV2Args{Timestamp: eth.Uint64(timestamp), PrevRandao: prevRandao, SuggestedFeeRecipient: d.feeRecipient, Withdrawals: d.withdrawals()}
+	default:
+		return &services.PayloadAttributesV3Args{Timestamp: eth.Uint64(timestamp), PrevRandao: prevRandao, SuggestedFeeRecipient: d.feeRecipient, Withdrawals: d.withdrawals(), ParentBeaconBlockRoot: d.parentBeaconBlockRoot()}
+	}
+}
+
+// withdrawals returns the synthetic withdrawals of the slot, validators withdraw in turn to
+// an address derived from their index.
+func (d *driver) withdrawals() []services.WithdrawalV1Args {
+	withdrawals := make([]services.WithdrawalV1Args, d.withdrawalsPerSlot)
+	for i := range withdrawals {
+		address := make([]byte, 20)
+		binary.BigEndian.PutUint64(address[12:], d.validatorIndex)
+
+		withdrawals[i] = services.WithdrawalV1Args{
+			Index:          eth.Uint64(d.withdrawalSeq),
+			ValidatorIndex: eth.Uint64(d.validatorIndex),
+			Address:        eth.Address(address),
+			Amount:         withdrawalAmountGwei,
+		}
+
+		d.withdrawalSeq++
+		d.validatorIndex++
+	}
+
+	return withdrawals
+}
+
+// parentBeaconBlockRoot is the synthetic root of the beacon block of the current slot's
+// parent, there is no beacon chain behind the driver.
+func (d *driver) parentBeaconBlockRoot() eth.Hash {
+	return eth.Hash(crypto.Keccak256(slotBytes("beacon", d.slot-1)))
+}
+
+func (d *driver) call(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, driveCallTimeout)
+	defer cancel()
+
+	if err := d.client.Call(ctx, method, params, out); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	return nil
+}
+
+func slotBytes(domain string, slot uint64) []byte {
+	out := make([]byte, len(domain)+8)
+	copy(out, domain)
+	binary.BigEndian.PutUint64(out[len(domain):], slot)
+
+	return out
+}
+
+func stringOrEmpty(in *string) string {
+	if in == nil {
+		return ""
+	}
+
+	return *in
+}
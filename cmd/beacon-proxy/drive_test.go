@@ -0,0 +1,78 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/config"
+	"github.com/streamingfast/geth-proxy/json-rpc/enginetest"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriver_Run(t *testing.T) {
+	ctx := context.Background()
+	engine := enginetest.NewEngine(1337)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	chainConfig, err := config.NetworkNameToChainConfig("battlefield")
+	require.NoError(t, err)
+
+	driver := &driver{
+		client:               upstream.NewClient(server.URL, nil),
+		chainConfig:          chainConfig,
+		feeRecipient:         eth.MustNewAddress("0x0000000000000000000000000000000000000002"),
+		finalizationDistance: 2,
+	}
+
+	head, err := driver.resolveHead(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, engine.Genesis().Hash, head.Hash)
+
+	driver.run(ctx, head, 10*time.Millisecond, 4)
+
+	require.Len(t, driver.chain, 3, "finalization distance plus the head")
+	assert.Equal(t, uint64(4), engine.Head().Number)
+	assert.Equal(t, driver.chain[2].Hash, engine.Head().Hash)
+	assert.Equal(t, driver.chain[0].Hash, engine.Finalized().Hash)
+
+	// Each slot makes the new block the head with its parent as the safe block
+	var states []*services.ForkchoiceStateV1Args
+	for _, call := range engine.Calls() {
+		if call.Method != "engine_forkchoiceUpdatedV1" || string(call.Params[1]) != "null" {
+			continue
+		}
+
+		state := &services.ForkchoiceStateV1Args{}
+		require.NoError(t, json.Unmarshal(call.Params[0], state))
+		states = append(states, state)
+	}
+
+	require.Len(t, states, 4)
+	for _, state := range states {
+		head := engine.Block(state.HeadBlockHash)
+		require.NotNil(t, head)
+		assert.Equal(t, head.ParentHash, state.SafeBlockHash, "block %d", head.Number)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/streamingfast/dmetrics"
 	"github.com/streamingfast/logging"
 	"go.uber.org/zap"
 	"net/http"
@@ -32,6 +33,8 @@ func main() {
 	Run("beacon-proxy", "Multi Reader-Node Proxy",
 		CommandOptionFunc(func(parent *cobra.Command) {
 			parent.AddCommand(ServeJSONRPCCommand)
+			parent.AddCommand(ReplayCommand)
+			parent.AddCommand(DriveCommand)
 		}),
 		ConfigureViper("LIGHTHOUSE"),
 		//ConfigureVersion(),
@@ -52,7 +55,7 @@ func main() {
 
 				if v := viper.GetString("global-metrics-listen-addr"); v != "" {
 					zlog.Info("starting prometheus metrics server", zap.String("listen_addr", v))
-					// go dmetrics.Serve(v)
+					go dmetrics.Serve(v)
 				}
 
 				if v := viper.GetString("global-pprof-listen-addr"); v != "" {
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"github.com/streamingfast/dmetrics"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+)
+
+func init() {
+	dmetrics.Register(
+		services.MetricsSet,
+	)
+}
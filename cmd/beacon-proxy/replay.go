@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/streamingfast/derr"
+	"github.com/streamingfast/geth-proxy/json-rpc/journal"
+	"github.com/streamingfast/geth-proxy/json-rpc/jwtauth"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// replayCallTimeout is more generous than the Engine API recommended timeouts, a fresh node
+// catching up executes payloads back to back and can take a while on heavy blocks.
+const replayCallTimeout = 2 * time.Minute
+
+func init() {
+	rootCmd.AddCommand(ReplayCommand)
+
+	ReplayCommand.Flags().String("execution-endpoint", "http://localhost:8551", "The Engine API endpoint of the execution node the journal is replayed into")
+	ReplayCommand.Flags().String("execution-jwt-secret", "", "Path to the hex encoded JWT secret file used to authenticate against --execution-endpoint, when empty requests are not authenticated")
+	ReplayCommand.Flags().String("since", "", "Only replay journal segments started at or after this RFC3339 time (e.g. 2023-04-12T00:00:00Z), the whole journal is replayed when empty")
+}
+
+var ReplayCommand = &cobra.Command{
+	Use:   "replay <journal-store-url>",
+	Short: "Replays the Engine API journal recorded by 'serve --journal-store-url' into an execution node, in order",
+	Args:  cobra.ExactArgs(1),
+	RunE:  replayE,
+}
+
+func replayE(cmd *cobra.Command, args []string) error {
+	journalStoreURL := args[0]
+	executionEndpoint := viper.GetString("replay-execution-endpoint")
+	executionJWTSecretPath := viper.GetString("replay-execution-jwt-secret")
+	sinceRaw := viper.GetString("replay-since")
+
+	var since time.Time
+	if sinceRaw != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, sinceRaw)
+		if err != nil {
+			return fmt.Errorf("invalid since time %q: %w", sinceRaw, err)
+		}
+	}
+
+	var secret []byte
+	if executionJWTSecretPath != "" {
+		var err error
+		secret, err = jwtauth.LoadSecret(executionJWTSecretPath)
+		if err != nil {
+			return fmt.Errorf("loading execution jwt secret: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-derr.SetupSignalHandler(0 * time.Second):
+			zlog.Info("signal received, stopping replay")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	zlog.Info("replaying journal", zap.String("journal_store_url", journalStoreURL), zap.String("execution_endpoint", executionEndpoint), zap.Time("since", since))
+
+	client := upstream.NewClient(executionEndpoint, secret)
+	stats := &replayStats{startedAt: time.Now()}
+
+	err := journal.Read(ctx, journalStoreURL, since, func(entry *journal.Entry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		status, err := replayEntry(ctx, client, entry)
+		if err != nil {
+			return fmt.Errorf("replaying %s recorded at %s: %w", entry.Method, entry.Time.Format(time.RFC3339Nano), err)
+		}
+
+		stats.record(entry, status)
+		if status == services.EnginePayloadStatusInvalid || status == services.EnginePayloadStatusInvalidBlockHash {
+			zlog.Warn("execution node rejected replayed call", zap.String("method", entry.Method), zap.Time("recorded_at", entry.Time), zap.String("status", string(status)))
+		}
+
+		if stats.calls%1000 == 0 {
+			zlog.Info("replay progress", zap.Object("stats", stats))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("replay failed after %d calls: %w", stats.calls, err)
+	}
+
+	zlog.Info("replay completed", zap.Object("stats", stats))
+	return nil
+}
+
+// replayEntry sends the recorded call as is and returns the payload status answered by the
+// execution node.
+func replayEntry(ctx context.Context, client *upstream.Client, entry *journal.Entry) (services.EnginePayloadStatus, error) {
+	var rawParams []json.RawMessage
+	if err := json.Unmarshal(entry.Params, &rawParams); err != nil {
+		return "", fmt.Errorf("decode recorded params: %w", err)
+	}
+
+	params := make([]interface{}, len(rawParams))
+	for i, rawParam := range rawParams {
+		params[i] = rawParam
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, replayCallTimeout)
+	defer cancel()
+
+	if strings.HasPrefix(entry.Method, "engine_forkchoiceUpdated") {
+		reply := &services.ForkchoiceUpdatedV1Reply{}
+		if err := client.Call(ctx, entry.Method, params, reply); err != nil {
+			return "", err
+		}
+
+		return reply.PayloadStatus.Status, nil
+	}
+
+	status := &services.PayloadStatusV1Args{}
+	if err := client.Call(ctx, entry.Method, params, status); err != nil {
+		return "", err
+	}
+
+	return status.Status, nil
+}
+
+type replayStats struct {
+	startedAt  time.Time
+	calls      uint64
+	statuses   map[services.EnginePayloadStatus]uint64
+	lastCallAt time.Time
+}
+
+func (s *replayStats) record(entry *journal.Entry, status services.EnginePayloadStatus) {
+	if s.statuses == nil {
+		s.statuses = map[services.EnginePayloadStatus]uint64{}
+	}
+
+	s.calls++
+	s.statuses[status]++
+	s.lastCallAt = entry.Time
+}
+
+func (s *replayStats) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddUint64("calls", s.calls)
+	encoder.AddDuration("elapsed", time.Since(s.startedAt))
+	encoder.AddTime("last_recorded_at", s.lastCallAt)
+	for status, count := range s.statuses {
+		encoder.AddUint64(strings.ToLower(string(status)), count)
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"go.uber.org/zap"
 	"time"
@@ -8,14 +9,35 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/streamingfast/derr"
+	pbtrxstream "github.com/streamingfast/firehose-ethereum/types/pb/sf/ethereum/trxstream/v1"
+	"github.com/streamingfast/geth-proxy/config"
 	jsonrpc "github.com/streamingfast/geth-proxy/json-rpc"
+	"github.com/streamingfast/geth-proxy/json-rpc/divergence"
+	"github.com/streamingfast/geth-proxy/json-rpc/journal"
+	"github.com/streamingfast/geth-proxy/json-rpc/jwtauth"
 	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func init() {
 	rootCmd.AddCommand(ServeJSONRPCCommand)
 
+	ServeJSONRPCCommand.Flags().String("network", "goerli", "The network the proxy serves, one of mainnet, goerli or battlefield, used to resolve the chain configuration answering eth_chainId and net_version. Every upstream must report the same chain id at startup")
 	ServeJSONRPCCommand.Flags().String("listen-addr-beacon", ":8080", "The port that should be listened too for incoming JSON-RPC requests")
+	ServeJSONRPCCommand.Flags().String("listen-ipc-path", "", "Path of a unix domain socket where the same JSON-RPC services are served with geth's IPC framing, for co-located tools. When empty, no IPC endpoint is served")
+	ServeJSONRPCCommand.Flags().Int("max-batch-size", 100, "The maximum number of calls accepted in a single JSON-RPC batch request, larger batches are rejected. No limit when 0")
+	ServeJSONRPCCommand.Flags().String("beacon-jwt-secret", "", "Path to the hex encoded JWT secret file shared with the consensus client, generated if missing, Engine API calls are rejected unless they are authenticated with it. When empty, authentication is disabled")
+	ServeJSONRPCCommand.Flags().StringSlice("execution-endpoints", nil, "Comma separated list of upstream execution node Engine API endpoints (e.g. http://localhost:8551) to which Engine API calls are forwarded")
+	ServeJSONRPCCommand.Flags().StringSlice("execution-jwt-secrets", nil, "Comma separated list of paths to hex encoded JWT secret files used to authenticate against each upstream of --execution-endpoints, in the same order. A single path applies to all upstreams, when empty requests are not authenticated")
+	ServeJSONRPCCommand.Flags().StringSlice("execution-rpc-endpoints", nil, "Comma separated list of the regular JSON-RPC endpoints (e.g. http://localhost:8545) of each upstream of --execution-endpoints, in the same order, to which eth, net and web3 calls not served by the proxy are forwarded. When empty, they are forwarded to the Engine API endpoints which usually only serve the eth namespace")
+	ServeJSONRPCCommand.Flags().String("execution-builder-endpoint", "", "The upstream execution endpoint, one of --execution-endpoints, receiving payload attributes and building payloads, defaults to the first endpoint")
+	ServeJSONRPCCommand.Flags().String("execution-quorum-policy", "any-valid", "How payload statuses are merged when upstreams disagree, one of 'primary' (the builder endpoint decides), 'any-valid' (a single VALID is enough), 'majority' (more than half of the upstreams must agree) or 'all' (all upstreams must agree), SYNCING is answered when no decision can be reached")
+	ServeJSONRPCCommand.Flags().String("divergence-store-url", "", "dstore URL (e.g. file:///data/divergences or gs://bucket/divergences) where a report is written each time upstreams disagree on the validity of a payload, reports are listed at /debug/divergences. When empty, divergences are only logged and counted")
+	ServeJSONRPCCommand.Flags().String("journal-store-url", "", "dstore URL (e.g. file:///data/journal or gs://bucket/journal) where every accepted newPayload and forkchoiceUpdated call is journaled, see the 'replay' command to catch up a fresh execution node from it. When empty, calls are not journaled")
+	ServeJSONRPCCommand.Flags().String("pending-transactions-stream-addr", "", "gRPC address (e.g. localhost:9000) of the transaction stream served by the node manager's transaction pool plugin, feeding 'newPendingTransactions' subscriptions. When empty, such subscriptions never receive notifications")
+	ServeJSONRPCCommand.Flags().String("forkchoice-state-path", "", "File where the last forkchoice state accepted by the upstreams is persisted, it's sent to each upstream execution node as soon as it's reachable, at startup or after a restart, so it resumes following the chain without waiting for the consensus client. When empty, the state is only kept in memory")
 }
 
 var ServeJSONRPCCommand = &cobra.Command{
@@ -25,24 +47,142 @@ var ServeJSONRPCCommand = &cobra.Command{
 }
 
 func serveJSONRPCE(cmd *cobra.Command, args []string) error {
+	network := viper.GetString("serve-network")
 	listenAddrBeacon := viper.GetString("serve-listen-addr-beacon")
+	listenIPCPath := viper.GetString("serve-listen-ipc-path")
+	maxBatchSize := viper.GetInt("serve-max-batch-size")
+	executionEndpoints := viper.GetStringSlice("serve-execution-endpoints")
+	executionJWTSecretPaths := viper.GetStringSlice("serve-execution-jwt-secrets")
+	executionRPCEndpoints := viper.GetStringSlice("serve-execution-rpc-endpoints")
+	executionBuilderEndpoint := viper.GetString("serve-execution-builder-endpoint")
+	executionQuorumPolicy := viper.GetString("serve-execution-quorum-policy")
+	divergenceStoreURL := viper.GetString("serve-divergence-store-url")
+	journalStoreURL := viper.GetString("serve-journal-store-url")
+	forkchoiceStatePath := viper.GetString("serve-forkchoice-state-path")
+	pendingTransactionsStreamAddr := viper.GetString("serve-pending-transactions-stream-addr")
+	beaconJWTSecretPath := viper.GetString("serve-beacon-jwt-secret")
 
-	zlog.Info("starting server", zap.String("listen_addr", listenAddrBeacon))
+	chainConfig, err := config.NetworkNameToChainConfig(network)
+	if err != nil {
+		return fmt.Errorf("invalid network: %w", err)
+	}
+
+	quorumPolicy, err := services.ParseQuorumPolicy(executionQuorumPolicy)
+	if err != nil {
+		return fmt.Errorf("invalid execution quorum policy: %w", err)
+	}
+
+	if len(executionEndpoints) == 0 {
+		return fmt.Errorf("at least one upstream execution endpoint must be provided via --execution-endpoints")
+	}
+
+	var beaconJWTSecret []byte
+	if beaconJWTSecretPath != "" {
+		secret, generated, err := jwtauth.LoadOrGenerateSecret(beaconJWTSecretPath)
+		if err != nil {
+			return fmt.Errorf("loading beacon jwt secret: %w", err)
+		}
+
+		if generated {
+			zlog.Info("generated new beacon jwt secret", zap.String("path", beaconJWTSecretPath))
+		}
+
+		beaconJWTSecret = secret
+	} else {
+		zlog.Warn("no beacon jwt secret configured, Engine API calls are not authenticated")
+	}
+
+	if executionBuilderEndpoint == "" {
+		executionBuilderEndpoint = executionEndpoints[0]
+	}
+
+	zlog.Info("starting server", zap.String("network", network), zap.String("listen_addr", listenAddrBeacon), zap.Strings("execution_endpoints", executionEndpoints), zap.Strings("execution_rpc_endpoints", executionRPCEndpoints), zap.String("execution_builder_endpoint", executionBuilderEndpoint), zap.String("execution_quorum_policy", string(quorumPolicy)))
+
+	upstreams, builder, err := newUpstreams(executionEndpoints, executionJWTSecretPaths, executionBuilderEndpoint)
+	if err != nil {
+		return err
+	}
+
+	readers, err := newReadUpstreams(executionRPCEndpoints, upstreams)
+	if err != nil {
+		return err
+	}
+
+	if err := checkUpstreamsChainID(context.Background(), chainConfig.ChainID.Uint64(), upstreams); err != nil {
+		return fmt.Errorf("upstreams must serve network %q: %w", network, err)
+	}
+
+	if len(executionRPCEndpoints) > 0 {
+		if err := checkUpstreamsChainID(context.Background(), chainConfig.ChainID.Uint64(), readers); err != nil {
+			return fmt.Errorf("upstreams read endpoints must serve network %q: %w", network, err)
+		}
+	}
+
+	var divergences *divergence.Store
+	if divergenceStoreURL != "" {
+		divergences, err = divergence.NewStore(divergenceStoreURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	var calls *journal.Writer
+	if journalStoreURL != "" {
+		calls, err = journal.NewWriter(journalStoreURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	subscriptions := services.NewSubscriptions(upstreams)
+	heads := upstream.NewHeadTracker(readers)
+	engineService := services.NewEngineService(chainConfig, upstreams, builder, quorumPolicy, divergences, calls, forkchoiceStatePath, subscriptions)
 
 	server, err := jsonrpc.NewServer(
 		listenAddrBeacon,
 		func() bool { return true },
 		[]services.ServiceHandler{
-			services.NewEngineService(),
-			services.NewEthService(),
+			engineService,
+			services.NewEthService(chainConfig, subscriptions),
+			services.NewNetService(chainConfig),
 		},
+		beaconJWTSecret,
+		divergences,
+		maxBatchSize,
+		heads,
 	)
 
 	if err != nil {
 		return fmt.Errorf("creating json rpc server: %w", err)
 	}
 
+	if calls != nil {
+		// Registered after the HTTP server shutdown, the pending journal segment is flushed
+		// once no more calls are accepted
+		server.OnTerminating(func(_ error) {
+			calls.Shutdown(nil)
+		})
+	}
+
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	server.OnTerminating(func(_ error) { stopWatching() })
+	go engineService.WatchUpstreams(watchCtx, heads)
+	go subscriptions.Run(watchCtx)
+
+	if pendingTransactionsStreamAddr != "" {
+		conn, err := grpc.Dial(pendingTransactionsStreamAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return fmt.Errorf("dialing pending transactions stream %q: %w", pendingTransactionsStreamAddr, err)
+		}
+		server.OnTerminated(func(_ error) { conn.Close() })
+
+		go subscriptions.FollowPendingTransactions(watchCtx, pbtrxstream.NewTransactionStreamClient(conn))
+	}
+
 	go server.Serve()
+	if listenIPCPath != "" {
+		go server.ServeIPC(listenIPCPath)
+	}
 
 	zlog.Info("waiting for server to terminate")
 
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/json-rpc/jwtauth"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"go.uber.org/zap"
+)
+
+const (
+	// chainIDCheckTimeout is how long we wait for upstreams to report their chain id at
+	// startup, execution nodes are often started at the same time as the proxy
+	chainIDCheckTimeout    = 1 * time.Minute
+	chainIDCheckRetryDelay = 2 * time.Second
+)
+
+// newUpstreams creates the upstream clients for each endpoint, `jwtSecretPaths` is either
+// empty, a single path shared by all endpoints or exactly one path per endpoint. The client
+// matching `builderEndpoint` is returned as the builder.
+func newUpstreams(endpoints []string, jwtSecretPaths []string, builderEndpoint string) (upstreams []*upstream.Client, builder *upstream.Client, err error) {
+	if len(jwtSecretPaths) > 1 && len(jwtSecretPaths) != len(endpoints) {
+		return nil, nil, fmt.Errorf("expected a single JWT secret or one per execution endpoint (%d) but got %d", len(endpoints), len(jwtSecretPaths))
+	}
+
+	upstreams = make([]*upstream.Client, len(endpoints))
+	for i, endpoint := range endpoints {
+		var secret []byte
+		if len(jwtSecretPaths) > 0 {
+			secretPath := jwtSecretPaths[0]
+			if len(jwtSecretPaths) > 1 {
+				secretPath = jwtSecretPaths[i]
+			}
+
+			secret, err = jwtauth.LoadSecret(secretPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("loading JWT secret for upstream %q: %w", endpoint, err)
+			}
+		}
+
+		upstreams[i] = upstream.NewClient(endpoint, secret)
+		if endpoint == builderEndpoint {
+			builder = upstreams[i]
+		}
+	}
+
+	if builder == nil {
+		return nil, nil, fmt.Errorf("builder endpoint %q is not one of the configured execution endpoints", builderEndpoint)
+	}
+
+	return upstreams, builder, nil
+}
+
+// newReadUpstreams creates the clients of the read endpoints of `upstreams`, `endpoints` is
+// either empty, in which case the upstreams serve reads themselves, or exactly one endpoint
+// per upstream. Read endpoints are not authenticated.
+func newReadUpstreams(endpoints []string, upstreams []*upstream.Client) ([]*upstream.Client, error) {
+	if len(endpoints) == 0 {
+		return upstreams, nil
+	}
+
+	if len(endpoints) != len(upstreams) {
+		return nil, fmt.Errorf("expected one read endpoint per execution endpoint (%d) but got %d", len(upstreams), len(endpoints))
+	}
+
+	readers := make([]*upstream.Client, len(endpoints))
+	for i, endpoint := range endpoints {
+		readers[i] = upstream.NewClient(endpoint, nil)
+	}
+
+	return readers, nil
+}
+
+// checkUpstreamsChainID ensures every upstream serves the chain identified by `chainID`, an
+// upstream that cannot be reached is retried until chainIDCheckTimeout elapses.
+func checkUpstreamsChainID(ctx context.Context, chainID uint64, upstreams []*upstream.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, chainIDCheckTimeout)
+	defer cancel()
+
+	for _, node := range upstreams {
+		for {
+			var reported eth.Uint64
+			err := node.Call(ctx, "eth_chainId", nil, &reported)
+			if err == nil {
+				if uint64(reported) != chainID {
+					return fmt.Errorf("upstream %q reports chain id %d but the network chain id is %d", node, reported, chainID)
+				}
+
+				break
+			}
+
+			zlog.Info("unable to get upstream chain id, retrying", zap.Stringer("upstream", node), zap.Duration("retry_delay", chainIDCheckRetryDelay), zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("unable to get upstream %q chain id: %w", node, err)
+			case <-time.After(chainIDCheckRetryDelay):
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/geth-proxy/json-rpc/enginetest"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUpstreamsChainID(t *testing.T) {
+	newUpstream := func(engine *enginetest.Engine) *upstream.Client {
+		server := httptest.NewServer(engine)
+		t.Cleanup(server.Close)
+
+		return upstream.NewClient(server.URL, nil)
+	}
+
+	t.Run("matching chain id", func(t *testing.T) {
+		upstreams := []*upstream.Client{newUpstream(enginetest.NewEngine(1337)), newUpstream(enginetest.NewEngine(1337))}
+
+		assert.NoError(t, checkUpstreamsChainID(context.Background(), 1337, upstreams))
+	})
+
+	t.Run("wrong chain id", func(t *testing.T) {
+		upstreams := []*upstream.Client{newUpstream(enginetest.NewEngine(1337)), newUpstream(enginetest.NewEngine(1))}
+
+		start := time.Now()
+		err := checkUpstreamsChainID(context.Background(), 1337, upstreams)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "reports chain id 1 but the network chain id is 1337")
+		assert.Less(t, time.Since(start), chainIDCheckRetryDelay, "a mismatch is not retried")
+	})
+
+	t.Run("upstream coming online is retried", func(t *testing.T) {
+		engine := enginetest.NewEngine(1337)
+		engine.SetOffline(true)
+		time.AfterFunc(100*time.Millisecond, func() { engine.SetOffline(false) })
+
+		assert.NoError(t, checkUpstreamsChainID(context.Background(), 1337, []*upstream.Client{newUpstream(engine)}))
+	})
+
+	t.Run("unreachable upstream", func(t *testing.T) {
+		offlineServer := httptest.NewServer(http.NotFoundHandler())
+		offline := upstream.NewClient(offlineServer.URL, nil)
+		offlineServer.Close()
+
+		// The caller's deadline applies when shorter than chainIDCheckTimeout
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		err := checkUpstreamsChainID(ctx, 1337, []*upstream.Client{offline})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to get upstream")
+	})
+}
+
+func TestNewReadUpstreams(t *testing.T) {
+	upstreams := []*upstream.Client{upstream.NewClient("http://localhost:8551", nil), upstream.NewClient("http://localhost:9551", nil)}
+
+	readers, err := newReadUpstreams(nil, upstreams)
+	require.NoError(t, err)
+	assert.Equal(t, upstreams, readers, "upstreams serve reads themselves")
+
+	readers, err = newReadUpstreams([]string{"http://localhost:8545", "http://localhost:9545"}, upstreams)
+	require.NoError(t, err)
+	require.Len(t, readers, 2)
+	assert.Equal(t, "http://localhost:8545", readers[0].String())
+	assert.Equal(t, "http://localhost:9545", readers[1].String())
+
+	_, err = newReadUpstreams([]string{"http://localhost:8545"}, upstreams)
+	assert.Error(t, err, "read endpoints are matched by index")
+}
@@ -17,14 +17,18 @@ func NetworkNameToChainConfig(networkName string) (*ChainConfig, error) {
 	switch networkName {
 	case "mainnet":
 		return &ChainConfig{
-			ChainConfig: params.MainnetChainConfig,
-			NetworkID:   1,
+			ChainConfig:  params.MainnetChainConfig,
+			NetworkID:    1,
+			ShanghaiTime: newUint64(1681338455),
+			CancunTime:   newUint64(1710338135),
 		}, nil
 
 	case "goerli":
 		return &ChainConfig{
-			ChainConfig: params.GoerliChainConfig,
-			NetworkID:   5,
+			ChainConfig:  params.GoerliChainConfig,
+			NetworkID:    5,
+			ShanghaiTime: newUint64(1678832736),
+			CancunTime:   newUint64(1705473120),
 		}, nil
 
 	case "battlefield":
@@ -34,7 +38,7 @@ func NetworkNameToChainConfig(networkName string) (*ChainConfig, error) {
 		}, nil
 
 	default:
-		return nil, nil
+		return nil, fmt.Errorf("unknown network %q, valid networks are mainnet, goerli and battlefield", networkName)
 	}
 }
 
@@ -43,6 +47,34 @@ type ChainConfig struct {
 
 	// NetworkID is the version peers must be using to allow exchange of information.
 	NetworkID uint64
+
+	// TerminalBlockHash and TerminalBlockNumber are the terminal proof-of-work block override
+	// exchanged with the consensus client, they are unset (zero) on all known networks.
+	TerminalBlockHash   common.Hash
+	TerminalBlockNumber uint64
+
+	// ShanghaiTime and CancunTime are the timestamps at which the post-merge forks activate,
+	// nil means the fork is not scheduled on this network.
+	ShanghaiTime *uint64
+	CancunTime   *uint64
+}
+
+// IsShanghaiTime returns true if the Shanghai fork is active for a block at `timestamp`.
+func (c *ChainConfig) IsShanghaiTime(timestamp uint64) bool {
+	return isForkedAt(c.ShanghaiTime, timestamp)
+}
+
+// IsCancunTime returns true if the Cancun fork is active for a block at `timestamp`.
+func (c *ChainConfig) IsCancunTime(timestamp uint64) bool {
+	return isForkedAt(c.CancunTime, timestamp)
+}
+
+func isForkedAt(forkTime *uint64, timestamp uint64) bool {
+	return forkTime != nil && *forkTime <= timestamp
+}
+
+func newUint64(value uint64) *uint64 {
+	return &value
 }
 
 var BattlefieldChainConfig = &params.ChainConfig{
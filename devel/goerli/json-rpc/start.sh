@@ -25,7 +25,9 @@ main() {
   fi
 
   exec $proxy serve\
-    --listen-addr-beacon=":8080"
+    --listen-addr-beacon=":8080"\
+    --beacon-jwt-secret="$ROOT/jwt.txt"\
+    --execution-endpoints="http://localhost:8551"
 }
 
 usage_error() {
@@ -0,0 +1,86 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/streamingfast/geth-proxy/json-rpc/jwtauth"
+	"github.com/streamingfast/logging"
+	"github.com/tidwall/gjson"
+	"go.uber.org/zap"
+)
+
+// newEngineAuthMiddleware rejects with `401 Unauthorized` any request containing an `engine_*`
+// call that does not carry a valid JWT bearer token signed with `secret`. Other namespaces
+// are left untouched.
+func newEngineAuthMiddleware(secret []byte) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "unable to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body.Close()
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			if !containsEngineCall(body) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" {
+				http.Error(w, "missing token", http.StatusUnauthorized)
+				return
+			}
+
+			if err := jwtauth.ValidateToken(secret, token, time.Now()); err != nil {
+				logging.Logger(r.Context(), zlog).Info("rejecting unauthenticated engine call", zap.Error(err))
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// containsEngineCall returns true if the single or batch JSON-RPC request in `body` calls at
+// least one method of the `engine` namespace.
+func containsEngineCall(body []byte) bool {
+	request := gjson.ParseBytes(body)
+	if !request.IsArray() {
+		return isEngineMethod(request.Get("method").String())
+	}
+
+	for _, method := range request.Get("#.method").Array() {
+		if isEngineMethod(method.String()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isEngineMethod(method string) bool {
+	return strings.HasPrefix(method, "engine_")
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/tidwall/gjson"
+)
+
+// batchConcurrency is the maximum number of calls of a single batch executed concurrently
+const batchConcurrency = 16
+
+// batchHandler serves JSON-RPC batches by dispatching each call of the batch to `next` as an
+// individual request, concurrently, and assembling the individual responses in the order of
+// the calls. A failing call only fails its own response. Single requests are handed to `next`
+// untouched.
+type batchHandler struct {
+	next         http.Handler
+	maxBatchSize int
+}
+
+func newBatchHandler(next http.Handler, maxBatchSize int) *batchHandler {
+	return &batchHandler{
+		next:         next,
+		maxBatchSize: maxBatchSize,
+	}
+}
+
+func (h *batchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusBadRequest)
+		return
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	message := gjson.ParseBytes(body)
+	if !message.IsArray() {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	calls := message.Array()
+	if len(calls) == 0 {
+		writeJSONRPCError(w, json2.E_INVALID_REQ, "empty batch")
+		return
+	}
+
+	if h.maxBatchSize > 0 && len(calls) > h.maxBatchSize {
+		writeJSONRPCError(w, json2.E_INVALID_REQ, fmt.Sprintf("batch of %d calls exceeds the limit of %d calls", len(calls), h.maxBatchSize))
+		return
+	}
+
+	responses := make([][]byte, len(calls))
+	semaphore := make(chan struct{}, batchConcurrency)
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(calls))
+	for i, call := range calls {
+		semaphore <- struct{}{}
+
+		go func(i int, call []byte) {
+			defer func() { <-semaphore; wg.Done() }()
+
+			request := r.Clone(r.Context())
+			request.Body = ioutil.NopCloser(bytes.NewReader(call))
+			request.ContentLength = int64(len(call))
+
+			response := newBufferedResponseWriter()
+			h.next.ServeHTTP(response, request)
+
+			responses[i] = response.body.Bytes()
+		}(i, []byte(call.Raw))
+	}
+	wg.Wait()
+
+	out := bytes.NewBuffer(nil)
+	for _, response := range responses {
+		// Notifications have no response, they are left out of the batch response
+		response = bytes.TrimSpace(response)
+		if len(response) == 0 {
+			continue
+		}
+
+		if !gjson.ValidBytes(response) {
+			response = jsonRPCError(json2.E_INTERNAL, string(response))
+		}
+
+		if out.Len() == 0 {
+			out.WriteByte('[')
+		} else {
+			out.WriteByte(',')
+		}
+		out.Write(response)
+	}
+
+	// A batch made only of notifications has no response at all
+	if out.Len() == 0 {
+		return
+	}
+	out.WriteByte(']')
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(out.Bytes())
+}
+
+// bufferedResponseWriter keeps the response of a single call of a batch in memory.
+type bufferedResponseWriter struct {
+	header http.Header
+	body   *bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		header: make(http.Header),
+		body:   bytes.NewBuffer(nil),
+	}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header         { return w.header }
+func (w *bufferedResponseWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
+func (w *bufferedResponseWriter) WriteHeader(_ int)           {}
+
+func writeJSONRPCError(w http.ResponseWriter, code json2.ErrorCode, message string) {
+	writeJSONRPCErrorResponse(w, nil, code, message)
+}
+
+func writeJSONRPCErrorResponse(w http.ResponseWriter, id json.RawMessage, code json2.ErrorCode, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(jsonRPCErrorResponse(id, code, message))
+}
+
+// jsonRPCError returns the JSON-RPC response of an error not tied to any request id.
+func jsonRPCError(code json2.ErrorCode, message string) []byte {
+	return jsonRPCErrorResponse(nil, code, message)
+}
+
+// jsonRPCErrorResponse returns the JSON-RPC response of an error for request `id`, `null`
+// when `id` is empty.
+func jsonRPCErrorResponse(id json.RawMessage, code json2.ErrorCode, message string) []byte {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	out, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   &json2.Error{Code: code, Message: message},
+	})
+
+	return out
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestBatchHandler(t *testing.T) {
+	// Echoes the id of the call as its result, the first calls being the slowest to ensure
+	// responses are assembled in order. Calls without id are notifications.
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		call := gjson.ParseBytes(body)
+
+		id := call.Get("id")
+		if !id.Exists() {
+			return
+		}
+
+		time.Sleep(time.Duration(10-id.Int()) * time.Millisecond)
+		if call.Get("method").String() == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"not found"},"id":` + id.Raw + `}`))
+			return
+		}
+
+		w.Write([]byte(`{"jsonrpc":"2.0","result":` + id.Raw + `,"id":` + id.Raw + `}`))
+	})
+
+	handler := newBatchHandler(next, 4)
+	serve := func(body string) string {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+
+		return recorder.Body.String()
+	}
+
+	assert.Equal(t, `{"jsonrpc":"2.0","result":1,"id":1}`, serve(`{"jsonrpc":"2.0","method":"ok","id":1}`), "single request")
+
+	assert.Equal(t,
+		`[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","error":{"code":-32601,"message":"not found"},"id":2},{"jsonrpc":"2.0","result":3,"id":3}]`,
+		serve(`[{"jsonrpc":"2.0","method":"ok","id":1},{"jsonrpc":"2.0","method":"fail","id":2},{"jsonrpc":"2.0","method":"notify"},{"jsonrpc":"2.0","method":"ok","id":3}]`),
+	)
+
+	assert.Equal(t, ``, serve(`[{"jsonrpc":"2.0","method":"notify"}]`), "notifications only")
+	assert.Equal(t, `{"error":{"code":-32600,"message":"empty batch"},"id":null,"jsonrpc":"2.0"}`, serve(`[]`))
+	assert.Equal(t, `{"error":{"code":-32600,"message":"batch of 5 calls exceeds the limit of 4 calls"},"id":null,"jsonrpc":"2.0"}`, serve(`[{},{},{},{},{}]`))
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/tidwall/gjson"
+)
+
+// connectionConcurrency is the maximum number of messages of a single persistent connection
+// served concurrently
+const connectionConcurrency = 16
+
+// rpcConnection serves the JSON-RPC messages received on a persistent connection, WebSocket
+// or IPC, through `next` as if each of them was the body of an HTTP POST request made with
+// `request`, so the same services and hooks serve every transport. Responses and
+// notifications are sent with `send`, which must be safe for concurrent use.
+//
+// Engine API calls are rejected on connections that are not `authenticated`.
+type rpcConnection struct {
+	next          http.Handler
+	request       *http.Request
+	authenticated bool
+	send          func(message []byte) error
+}
+
+// serve dispatches each message returned by `read` until it fails and returns its error,
+// once all dispatched messages have been served.
+func (c *rpcConnection) serve(read func() ([]byte, error)) error {
+	semaphore := make(chan struct{}, connectionConcurrency)
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	for {
+		message, err := read()
+		if err != nil {
+			return err
+		}
+
+		semaphore <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer func() { <-semaphore; wg.Done() }()
+
+			c.dispatch(message)
+		}()
+	}
+}
+
+// dispatch serves `message` through `next` and sends its response, nothing is sent when
+// `message` only contains notifications.
+func (c *rpcConnection) dispatch(message []byte) {
+	if !c.authenticated && containsEngineCall(message) {
+		id := gjson.GetBytes(message, "id")
+		c.send(jsonRPCErrorResponse([]byte(id.Raw), json2.E_INVALID_REQ, "engine calls require a connection authenticated with a valid token"))
+		return
+	}
+
+	// Subscriptions created by this message only start notifying once its response is sent
+	notifier := &connectionNotifier{connection: c, ready: make(chan struct{})}
+	defer close(notifier.ready)
+
+	request := c.request.Clone(services.WithNotifier(c.request.Context(), notifier))
+	request.Method = "POST"
+	request.Body = ioutil.NopCloser(bytes.NewReader(message))
+	request.ContentLength = int64(len(message))
+	request.Header.Set("Content-Type", "application/json")
+
+	response := newBufferedResponseWriter()
+	c.next.ServeHTTP(response, request)
+
+	if response := bytes.TrimSpace(response.body.Bytes()); len(response) > 0 {
+		c.send(response)
+	}
+}
+
+// connectionNotifier pushes the notifications of the subscriptions created by a single
+// message, they are held until the response of that message is sent.
+type connectionNotifier struct {
+	connection *rpcConnection
+	ready      chan struct{}
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+func (n *connectionNotifier) Notify(method string, params interface{}) error {
+	ctx := n.connection.request.Context()
+
+	select {
+	case <-n.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	message, err := json.Marshal(&notification{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("marshal notification: %w", err)
+	}
+
+	return n.connection.send(message)
+}
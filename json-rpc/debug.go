@@ -0,0 +1,67 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/streamingfast/dstore"
+	"github.com/streamingfast/geth-proxy/json-rpc/divergence"
+	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
+)
+
+// registerDivergenceRoutes exposes the divergence reports, `/debug/divergences` lists the
+// names of all reports and `/debug/divergences/{name}` returns a single report.
+func registerDivergenceRoutes(router *mux.Router, store *divergence.Store) {
+	router.Path("/debug/divergences").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names, err := store.List(r.Context())
+		if err != nil {
+			logging.Logger(r.Context(), zlog).Error("unable to list divergence reports", zap.Error(err))
+			http.Error(w, "unable to list divergence reports", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, r, names)
+	})
+
+	router.Path("/debug/divergences/{name}").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		report, err := store.Get(r.Context(), name)
+		if err != nil {
+			if errors.Is(err, dstore.ErrNotFound) {
+				http.Error(w, "divergence report not found", http.StatusNotFound)
+				return
+			}
+
+			logging.Logger(r.Context(), zlog).Error("unable to get divergence report", zap.String("name", name), zap.Error(err))
+			http.Error(w, "unable to get divergence report", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, r, report)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logging.Logger(r.Context(), zlog).Info("unable to write response", zap.Error(err))
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package divergence persists reports about execution layer consensus splits, upstream
+// execution nodes disagreeing on the validity of the same payload.
+package divergence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/streamingfast/dstore"
+)
+
+// Report describes a payload on which upstreams disagreed along with the answer of each.
+type Report struct {
+	DetectedAt  time.Time           `json:"detectedAt"`
+	Method      string              `json:"method"`
+	BlockHash   string              `json:"blockHash"`
+	BlockNumber uint64              `json:"blockNumber,omitempty"`
+	Params      json.RawMessage     `json:"params"`
+	Responses   []*UpstreamResponse `json:"responses"`
+}
+
+type UpstreamResponse struct {
+	Upstream      string          `json:"upstream"`
+	ClientVersion string          `json:"clientVersion"`
+	Response      json.RawMessage `json:"response,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// Name is the name under which the report is stored, reports sort chronologically by name.
+func (r *Report) Name() string {
+	return fmt.Sprintf("%010d-%s-%s", r.DetectedAt.Unix(), r.Method, r.BlockHash)
+}
+
+// Store saves reports as individual JSON files in a dstore location.
+type Store struct {
+	store dstore.Store
+}
+
+func NewStore(storeURL string) (*Store, error) {
+	store, err := dstore.NewStore(storeURL, "json", "", false)
+	if err != nil {
+		return nil, fmt.Errorf("new divergence store %q: %w", storeURL, err)
+	}
+
+	return &Store{store: store}, nil
+}
+
+func (s *Store) Save(ctx context.Context, report *Report) error {
+	content, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("marshal report: %w", err)
+	}
+
+	if err := s.store.WriteObject(ctx, report.Name(), bytes.NewReader(content)); err != nil {
+		return fmt.Errorf("write report %q: %w", report.Name(), err)
+	}
+
+	return nil
+}
+
+// List returns the names of all stored reports, oldest first.
+func (s *Store) List(ctx context.Context) ([]string, error) {
+	names := []string{}
+	err := s.store.Walk(ctx, "", func(filename string) error {
+		names = append(names, filename)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk reports: %w", err)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Get returns the report stored under `name`, the error wraps `dstore.ErrNotFound` if there
+// is no such report.
+func (s *Store) Get(ctx context.Context, name string) (*Report, error) {
+	reader, err := s.store.OpenObject(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("open report %q: %w", name, err)
+	}
+	defer reader.Close()
+
+	report := &Report{}
+	if err := json.NewDecoder(reader).Decode(report); err != nil {
+		return nil, fmt.Errorf("decode report %q: %w", name, err)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package divergence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewStore("file://" + t.TempDir())
+	require.NoError(t, err)
+
+	names, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	first := &Report{
+		DetectedAt:  time.Unix(1_700_000_000, 0).UTC(),
+		Method:      "engine_newPayloadV2",
+		BlockHash:   "0x01",
+		BlockNumber: 10,
+		Params:      json.RawMessage(`[{"blockHash":"0x01"}]`),
+		Responses: []*UpstreamResponse{
+			{Upstream: "http://a", ClientVersion: "Geth/v1.13.0", Response: json.RawMessage(`{"status":"VALID"}`)},
+			{Upstream: "http://b", ClientVersion: "Geth/v1.13.1", Response: json.RawMessage(`{"status":"INVALID"}`)},
+		},
+	}
+	second := &Report{DetectedAt: first.DetectedAt.Add(time.Minute), Method: "engine_forkchoiceUpdatedV2", BlockHash: "0x02"}
+
+	require.NoError(t, store.Save(ctx, second))
+	require.NoError(t, store.Save(ctx, first))
+
+	names, err = store.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{first.Name(), second.Name()}, names)
+
+	report, err := store.Get(ctx, first.Name())
+	require.NoError(t, err)
+	assert.Equal(t, first, report)
+
+	_, err = store.Get(ctx, "unknown")
+	assert.True(t, errors.Is(err, dstore.ErrNotFound))
+}
@@ -0,0 +1,551 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package enginetest provides an in-memory execution engine serving the Engine API, a stand-in
+// for geth to exercise the proxy's fan-out, quorum and failover logic in tests or to back a
+// local devnet without any execution client binary.
+package enginetest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/streamingfast/eth-go"
+	ethrpc "github.com/streamingfast/eth-go/rpc"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+)
+
+// Block is a block of the in-memory chain of the engine.
+type Block struct {
+	Hash       eth.Hash
+	ParentHash eth.Hash
+	Number     uint64
+	Timestamp  uint64
+
+	transactions []eth.Hex
+	withdrawals  []services.WithdrawalV1Args
+}
+
+// Call is a JSON-RPC call received by the engine.
+type Call struct {
+	Method string
+	Params []json.RawMessage
+}
+
+// Engine is an execution engine keeping an in-memory chain of the payloads it receives. It
+// accepts every payload whose parent is known, follows the forkchoice updates and builds
+// empty payloads on top of its head. Responses can be scripted per block number or per
+// method to simulate a syncing, diverging or failing execution client.
+//
+// Engine is an `http.Handler`, serve it with `httptest.NewServer` in tests or with
+// `http.ListenAndServe` for a devnet.
+type Engine struct {
+	chainID uint64
+
+	lock      sync.Mutex
+	blocks    map[string]*Block
+	genesis   *Block
+	head      *Block
+	safe      *Block
+	finalized *Block
+
+	payloads      map[string]*builtPayload
+	lastPayloadID uint64
+
+	blockScripts  map[uint64]*services.PayloadStatusV1Args
+	methodScripts map[string]*ethrpc.ErrResponse
+	offline       bool
+
+	calls []*Call
+}
+
+func NewEngine(chainID uint64) *Engine {
+	genesis := &Block{Hash: eth.Hash(crypto.Keccak256([]byte("enginetest genesis")))}
+
+	e := &Engine{
+		chainID:       chainID,
+		blocks:        make(map[string]*Block),
+		genesis:       genesis,
+		head:          genesis,
+		safe:          genesis,
+		finalized:     genesis,
+		payloads:      make(map[string]*builtPayload),
+		blockScripts:  make(map[uint64]*services.PayloadStatusV1Args),
+		methodScripts: make(map[string]*ethrpc.ErrResponse),
+	}
+	e.blocks[genesis.Hash.String()] = genesis
+
+	return e
+}
+
+// Genesis returns the first block of the chain, the parent of the first payload.
+func (e *Engine) Genesis() *Block {
+	return e.genesis
+}
+
+// Head returns the head block as set by the last forkchoice update.
+func (e *Engine) Head() *Block {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.head
+}
+
+// Finalized returns the finalized block as set by the last forkchoice update.
+func (e *Engine) Finalized() *Block {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.finalized
+}
+
+// Block returns the block with `hash` or nil if the engine doesn't know it.
+func (e *Engine) Block(hash eth.Hash) *Block {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.blocks[hash.String()]
+}
+
+// ScriptBlock makes the engine answer `status` to the payload of block `number` and to the
+// forkchoice updates having it as their head, instead of processing them. The payload is
+// added to the chain only if `status` is VALID.
+func (e *Engine) ScriptBlock(number uint64, status *services.PayloadStatusV1Args) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.blockScripts[number] = status
+}
+
+// ScriptError makes the engine answer every call to `method` with a JSON-RPC error.
+func (e *Engine) ScriptError(method string, code int, message string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.methodScripts[method] = &ethrpc.ErrResponse{Code: ethrpc.ErrorCode(code), Message: message}
+}
+
+// ResetScripts removes all the scripted responses, the engine processes calls normally again.
+func (e *Engine) ResetScripts() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.blockScripts = make(map[uint64]*services.PayloadStatusV1Args)
+	e.methodScripts = make(map[string]*ethrpc.ErrResponse)
+}
+
+// SetOffline makes the engine answer every request with a 503 HTTP error, like an execution
+// client being restarted behind a load balancer.
+func (e *Engine) SetOffline(offline bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.offline = offline
+}
+
+// Calls returns the calls received so far, in order.
+func (e *Engine) Calls() []*Call {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	out := make([]*Call, len(e.calls))
+	copy(out, e.calls)
+
+	return out
+}
+
+// CallCount returns the number of calls to `method` received so far.
+func (e *Engine) CallCount(method string) (count int) {
+	for _, call := range e.Calls() {
+		if call.Method == method {
+			count++
+		}
+	}
+
+	return
+}
+
+// Syncing is the status of an engine missing the ancestors of a payload.
+func Syncing() *services.PayloadStatusV1Args {
+	return &services.PayloadStatusV1Args{Status: services.EnginePayloadStatusSyncing}
+}
+
+// Invalid is the status of an engine rejecting a payload, `latestValidHash` is the last
+// valid ancestor of the payload.
+func Invalid(latestValidHash eth.Hash, validationError string) *services.PayloadStatusV1Args {
+	return &services.PayloadStatusV1Args{Status: services.EnginePayloadStatusInvalid, LatestValidHash: &latestValidHash, ValidationError: &validationError}
+}
+
+func valid(hash eth.Hash) *services.PayloadStatusV1Args {
+	return &services.PayloadStatusV1Args{Status: services.EnginePayloadStatusValid, LatestValidHash: &hash}
+}
+
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type resultResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+}
+
+type errorResponse struct {
+	JSONRPC string              `json:"jsonrpc"`
+	ID      json.RawMessage     `json:"id"`
+	Error   *ethrpc.ErrResponse `json:"error"`
+}
+
+func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.lock.Lock()
+	offline := e.offline
+	e.lock.Unlock()
+
+	if offline {
+		http.Error(w, "engine is offline", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &request{}
+	if err := json.Unmarshal(body, req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid JSON-RPC request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	var response interface{}
+	result, rpcErr := e.handle(req.Method, req.Params)
+	if rpcErr != nil {
+		response = &errorResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	} else {
+		response = &resultResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+	}
+
+	content, err := ethrpc.MarshalJSONRPC(response)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("encode response: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
+
+func (e *Engine) handle(method string, params []json.RawMessage) (interface{}, *ethrpc.ErrResponse) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.calls = append(e.calls, &Call{Method: method, Params: params})
+
+	if scripted, found := e.methodScripts[method]; found {
+		return nil, scripted
+	}
+
+	switch method {
+	case "engine_newPayloadV1", "engine_newPayloadV2", "engine_newPayloadV3":
+		return e.newPayload(params)
+	case "engine_forkchoiceUpdatedV1", "engine_forkchoiceUpdatedV2", "engine_forkchoiceUpdatedV3":
+		return e.forkchoiceUpdated(params)
+	case "engine_getPayloadV1":
+		return e.getPayload(params, payloadV1)
+	case "engine_getPayloadV2":
+		return e.getPayload(params, payloadV2)
+	case "engine_getPayloadV3":
+		return e.getPayload(params, payloadV3)
+	case "engine_getPayloadBodiesByHashV1":
+		return e.getPayloadBodiesByHash(params)
+	case "engine_getPayloadBodiesByRangeV1":
+		return e.getPayloadBodiesByRange(params)
+	case "engine_exchangeTransitionConfigurationV1":
+		return json.RawMessage(param(params, 0)), nil
+	case "engine_exchangeCapabilities":
+		return Capabilities(), nil
+	case "eth_chainId":
+		return eth.Uint64(e.chainID), nil
+	case "eth_blockNumber":
+		return eth.Uint64(e.head.Number), nil
+	case "eth_syncing":
+		// The engine follows the consensus client without any sync, scripted statuses only
+		// concern Engine API calls
+		return false, nil
+	case "eth_getBlockByNumber":
+		return e.getBlockByNumber(params)
+	case "eth_getBlockByHash":
+		return e.getBlockByHash(params)
+	case "engine_getClientVersionV1":
+		return []*services.ClientVersionV1{ClientVersion()}, nil
+	}
+
+	return nil, &ethrpc.ErrResponse{Code: -32601, Message: fmt.Sprintf("the method %s does not exist/is not available", method)}
+}
+
+// ClientVersion returns the version the engine answers to `engine_getClientVersionV1`.
+func ClientVersion() *services.ClientVersionV1 {
+	return &services.ClientVersionV1{Code: "ET", Name: "enginetest", Version: "v0.0.0", Commit: "00000000"}
+}
+
+// Capabilities returns the Engine API methods served by the engine.
+func Capabilities() []string {
+	capabilities := []string{
+		"engine_newPayloadV1", "engine_newPayloadV2", "engine_newPayloadV3",
+		"engine_forkchoiceUpdatedV1", "engine_forkchoiceUpdatedV2", "engine_forkchoiceUpdatedV3",
+		"engine_getPayloadV1", "engine_getPayloadV2", "engine_getPayloadV3",
+		"engine_getPayloadBodiesByHashV1", "engine_getPayloadBodiesByRangeV1",
+		"engine_exchangeTransitionConfigurationV1",
+	}
+	sort.Strings(capabilities)
+
+	return capabilities
+}
+
+func (e *Engine) newPayload(params []json.RawMessage) (interface{}, *ethrpc.ErrResponse) {
+	payload := &services.ExecutionPayloadV3Args{}
+	if err := decodeParam(params, 0, payload); err != nil {
+		return nil, err
+	}
+
+	block := &Block{
+		Hash:         payload.BlockHash,
+		ParentHash:   payload.ParentHash,
+		Number:       uint64(payload.BlockNumber),
+		Timestamp:    uint64(payload.Timestamp),
+		transactions: payload.Transactions,
+		withdrawals:  payload.Withdrawals,
+	}
+
+	if status, found := e.blockScripts[block.Number]; found {
+		if status.Status == services.EnginePayloadStatusValid {
+			e.blocks[block.Hash.String()] = block
+		}
+
+		return status, nil
+	}
+
+	if _, found := e.blocks[block.ParentHash.String()]; !found {
+		return Syncing(), nil
+	}
+
+	e.blocks[block.Hash.String()] = block
+	return valid(block.Hash), nil
+}
+
+func (e *Engine) forkchoiceUpdated(params []json.RawMessage) (interface{}, *ethrpc.ErrResponse) {
+	state := &services.ForkchoiceStateV1Args{}
+	if err := decodeParam(params, 0, state); err != nil {
+		return nil, err
+	}
+
+	var attributes *services.PayloadAttributesV3Args
+	if err := decodeParam(params, 1, &attributes); err != nil {
+		return nil, err
+	}
+
+	head, found := e.blocks[state.HeadBlockHash.String()]
+	if !found {
+		return &services.ForkchoiceUpdatedV1Reply{PayloadStatus: *Syncing()}, nil
+	}
+
+	if status, found := e.blockScripts[head.Number]; found && status.Status != services.EnginePayloadStatusValid {
+		return &services.ForkchoiceUpdatedV1Reply{PayloadStatus: *status}, nil
+	}
+
+	e.head = head
+	if safe, found := e.blocks[state.SafeBlockHash.String()]; found {
+		e.safe = safe
+	}
+	if finalized, found := e.blocks[state.FinalizedBlockHash.String()]; found {
+		e.finalized = finalized
+	}
+
+	reply := &services.ForkchoiceUpdatedV1Reply{PayloadStatus: *valid(head.Hash)}
+	if attributes != nil {
+		payloadID, err := e.buildPayload(head, attributes)
+		if err != nil {
+			return nil, &ethrpc.ErrResponse{Code: -38003, Message: fmt.Sprintf("Invalid payload attributes: %s", err)}
+		}
+
+		reply.PayloadID = &payloadID
+	}
+
+	return reply, nil
+}
+
+func (e *Engine) getPayload(params []json.RawMessage, version payloadVersion) (interface{}, *ethrpc.ErrResponse) {
+	var payloadID eth.Hex
+	if err := decodeParam(params, 0, &payloadID); err != nil {
+		return nil, err
+	}
+
+	payload, found := e.payloads[payloadID.String()]
+	if !found {
+		return nil, &ethrpc.ErrResponse{Code: -38001, Message: "Unknown payload"}
+	}
+
+	return payload.reply(version), nil
+}
+
+type payloadBody struct {
+	Transactions []eth.Hex                   `json:"transactions"`
+	Withdrawals  []services.WithdrawalV1Args `json:"withdrawals"`
+}
+
+func (b *Block) body() *payloadBody {
+	transactions := b.transactions
+	if transactions == nil {
+		transactions = []eth.Hex{}
+	}
+
+	return &payloadBody{Transactions: transactions, Withdrawals: b.withdrawals}
+}
+
+func (e *Engine) getPayloadBodiesByHash(params []json.RawMessage) (interface{}, *ethrpc.ErrResponse) {
+	var hashes []eth.Hash
+	if err := decodeParam(params, 0, &hashes); err != nil {
+		return nil, err
+	}
+
+	bodies := make([]*payloadBody, len(hashes))
+	for i, hash := range hashes {
+		if block, found := e.blocks[hash.String()]; found {
+			bodies[i] = block.body()
+		}
+	}
+
+	return bodies, nil
+}
+
+func (e *Engine) getPayloadBodiesByRange(params []json.RawMessage) (interface{}, *ethrpc.ErrResponse) {
+	var start, count eth.Uint64
+	if err := decodeParam(params, 0, &start); err != nil {
+		return nil, err
+	}
+	if err := decodeParam(params, 1, &count); err != nil {
+		return nil, err
+	}
+
+	var bodies []*payloadBody
+	for number := uint64(start); number < uint64(start+count) && number <= e.head.Number; number++ {
+		bodies = append(bodies, e.canonicalBlock(number).body())
+	}
+
+	if bodies == nil {
+		bodies = []*payloadBody{}
+	}
+
+	return bodies, nil
+}
+
+// canonicalBlock returns the block at `number` on the chain ending at the head, `number`
+// must not be above the head.
+func (e *Engine) canonicalBlock(number uint64) *Block {
+	block := e.head
+	for block.Number > number {
+		block = e.blocks[block.ParentHash.String()]
+	}
+
+	return block
+}
+
+type rpcBlock struct {
+	Hash       eth.Hash   `json:"hash"`
+	ParentHash eth.Hash   `json:"parentHash"`
+	Number     eth.Uint64 `json:"number"`
+	Timestamp  eth.Uint64 `json:"timestamp"`
+}
+
+func (b *Block) rpc() *rpcBlock {
+	return &rpcBlock{Hash: b.Hash, ParentHash: b.ParentHash, Number: eth.Uint64(b.Number), Timestamp: eth.Uint64(b.Timestamp)}
+}
+
+func (e *Engine) getBlockByNumber(params []json.RawMessage) (interface{}, *ethrpc.ErrResponse) {
+	var tag string
+	if err := decodeParam(params, 0, &tag); err != nil {
+		return nil, err
+	}
+
+	var block *Block
+	switch tag {
+	case "latest", "pending":
+		block = e.head
+	case "safe":
+		block = e.safe
+	case "finalized":
+		block = e.finalized
+	case "earliest":
+		block = e.genesis
+	default:
+		var number eth.Uint64
+		if err := number.UnmarshalText([]byte(tag)); err != nil {
+			return nil, &ethrpc.ErrResponse{Code: -32602, Message: fmt.Sprintf("invalid block number %q: %s", tag, err)}
+		}
+
+		if uint64(number) > e.head.Number {
+			return nil, nil
+		}
+		block = e.canonicalBlock(uint64(number))
+	}
+
+	return block.rpc(), nil
+}
+
+func (e *Engine) getBlockByHash(params []json.RawMessage) (interface{}, *ethrpc.ErrResponse) {
+	var hash eth.Hash
+	if err := decodeParam(params, 0, &hash); err != nil {
+		return nil, err
+	}
+
+	if block, found := e.blocks[hash.String()]; found {
+		return block.rpc(), nil
+	}
+
+	return nil, nil
+}
+
+func (e *Engine) nextPayloadID() eth.Hex {
+	e.lastPayloadID++
+
+	id := make([]byte, 8)
+	binary.BigEndian.PutUint64(id, e.lastPayloadID)
+
+	return eth.Hex(id)
+}
+
+func param(params []json.RawMessage, index int) []byte {
+	if index >= len(params) {
+		return []byte("null")
+	}
+
+	return params[index]
+}
+
+func decodeParam(params []json.RawMessage, index int, out interface{}) *ethrpc.ErrResponse {
+	if err := json.Unmarshal(param(params, index), out); err != nil {
+		return &ethrpc.ErrResponse{Code: -32602, Message: fmt.Sprintf("invalid argument %d: %s", index, err)}
+	}
+
+	return nil
+}
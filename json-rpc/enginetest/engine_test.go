@@ -0,0 +1,102 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enginetest
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_BuildAndFollow(t *testing.T) {
+	ctx := context.Background()
+	engine := NewEngine(1337)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+	client := upstream.NewClient(server.URL, nil)
+
+	state := &services.ForkchoiceStateV1Args{HeadBlockHash: engine.Genesis().Hash, SafeBlockHash: engine.Genesis().Hash, FinalizedBlockHash: engine.Genesis().Hash}
+	attributes := &services.PayloadAttributesV2Args{Timestamp: 12, PrevRandao: eth.MustNewHash("0x01"), SuggestedFeeRecipient: eth.MustNewAddress("0x0000000000000000000000000000000000000002"), Withdrawals: []services.WithdrawalV1Args{{Index: 1, ValidatorIndex: 2, Address: eth.MustNewAddress("0x0000000000000000000000000000000000000003"), Amount: 4}}}
+
+	forkchoice := &services.ForkchoiceUpdatedV1Reply{}
+	require.NoError(t, client.Call(ctx, "engine_forkchoiceUpdatedV2", []interface{}{state, attributes}, forkchoice))
+	assert.Equal(t, services.EnginePayloadStatusValid, forkchoice.PayloadStatus.Status)
+	require.NotNil(t, forkchoice.PayloadID)
+
+	built := &services.GetPayloadV2Reply{}
+	require.NoError(t, client.Call(ctx, "engine_getPayloadV2", []interface{}{forkchoice.PayloadID}, built))
+	payload := &built.ExecutionPayload
+	assert.Equal(t, engine.Genesis().Hash, payload.ParentHash)
+	assert.Equal(t, eth.Uint64(1), payload.BlockNumber)
+	assert.Len(t, payload.Withdrawals, 1)
+
+	computed, err := payload.ComputeBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, computed, payload.BlockHash)
+
+	status := &services.PayloadStatusV1Args{}
+	require.NoError(t, client.Call(ctx, "engine_newPayloadV2", []interface{}{payload}, status))
+	assert.Equal(t, services.EnginePayloadStatusValid, status.Status)
+
+	state.HeadBlockHash = payload.BlockHash
+	require.NoError(t, client.Call(ctx, "engine_forkchoiceUpdatedV2", []interface{}{state, nil}, forkchoice))
+	assert.Equal(t, services.EnginePayloadStatusValid, forkchoice.PayloadStatus.Status)
+	assert.Nil(t, forkchoice.PayloadID)
+	assert.Equal(t, uint64(1), engine.Head().Number)
+
+	bodies := []*payloadBody{}
+	require.NoError(t, client.Call(ctx, "engine_getPayloadBodiesByRangeV1", []interface{}{eth.Uint64(1), eth.Uint64(2)}, &bodies))
+	require.Len(t, bodies, 1)
+	assert.Len(t, bodies[0].Withdrawals, 1)
+
+	assert.Equal(t, 2, engine.CallCount("engine_forkchoiceUpdatedV2"))
+}
+
+func TestEngine_Scripts(t *testing.T) {
+	ctx := context.Background()
+	engine := NewEngine(1337)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+	client := upstream.NewClient(server.URL, nil)
+
+	orphan := &services.ExecutionPayloadV1Args{ParentHash: eth.MustNewHash("0xff"), BlockHash: eth.MustNewHash("0x02"), BlockNumber: 2}
+	status := &services.PayloadStatusV1Args{}
+	require.NoError(t, client.Call(ctx, "engine_newPayloadV1", []interface{}{orphan}, status))
+	assert.Equal(t, services.EnginePayloadStatusSyncing, status.Status, "unknown parent")
+
+	child := &services.ExecutionPayloadV1Args{ParentHash: engine.Genesis().Hash, BlockHash: eth.MustNewHash("0x01"), BlockNumber: 1}
+	engine.ScriptBlock(1, Invalid(engine.Genesis().Hash, "bad state root"))
+	require.NoError(t, client.Call(ctx, "engine_newPayloadV1", []interface{}{child}, status))
+	assert.Equal(t, services.EnginePayloadStatusInvalid, status.Status)
+	assert.Equal(t, "bad state root", *status.ValidationError)
+	assert.Nil(t, engine.Block(child.BlockHash))
+
+	engine.ScriptError("engine_newPayloadV1", -32000, "database corrupted")
+	err := client.Call(ctx, "engine_newPayloadV1", []interface{}{child}, status)
+	assert.EqualError(t, err, "rpc error (code -32000): database corrupted")
+
+	engine.ResetScripts()
+	require.NoError(t, client.Call(ctx, "engine_newPayloadV1", []interface{}{child}, status))
+	assert.Equal(t, services.EnginePayloadStatusValid, status.Status)
+
+	engine.SetOffline(true)
+	assert.Error(t, client.Call(ctx, "engine_newPayloadV1", []interface{}{child}, status))
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enginetest
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+)
+
+const (
+	builtPayloadGasLimit = 30_000_000
+	builtPayloadBaseFee  = 7
+)
+
+type payloadVersion uint8
+
+const (
+	payloadV1 payloadVersion = iota + 1
+	payloadV2
+	payloadV3
+)
+
+// builtPayload is an empty payload built on top of the head, its shape follows the payload
+// attributes it was built from: withdrawals from Shanghai and a parent beacon block root from
+// Cancun.
+type builtPayload struct {
+	payload               *services.ExecutionPayloadV3Args
+	parentBeaconBlockRoot eth.Hash
+}
+
+// buildPayload builds an empty payload on top of `parent` and returns its payload id. The
+// payload's block hash is computed from its fields so the payload passes the block hash
+// verification of the proxy and execution clients.
+func (e *Engine) buildPayload(parent *Block, attributes *services.PayloadAttributesV3Args) (eth.Hex, error) {
+	built := &builtPayload{
+		payload: &services.ExecutionPayloadV3Args{
+			ParentHash:    parent.Hash,
+			FeeRecipient:  attributes.SuggestedFeeRecipient,
+			StateRoot:     eth.Hash(types.EmptyRootHash.Bytes()),
+			ReceiptsRoot:  eth.Hash(types.EmptyRootHash.Bytes()),
+			LogsBloom:     make(eth.Hex, types.BloomByteLength),
+			PrevRandao:    attributes.PrevRandao,
+			BlockNumber:   eth.Uint64(parent.Number + 1),
+			GasLimit:      builtPayloadGasLimit,
+			Timestamp:     attributes.Timestamp,
+			ExtraData:     eth.Hex("enginetest"),
+			BaseFeePerGas: services.BigInt(*big.NewInt(builtPayloadBaseFee)),
+			Transactions:  []eth.Hex{},
+			Withdrawals:   attributes.Withdrawals,
+		},
+		parentBeaconBlockRoot: attributes.ParentBeaconBlockRoot,
+	}
+
+	blockHash, err := built.blockHash()
+	if err != nil {
+		return nil, err
+	}
+	built.payload.BlockHash = blockHash
+
+	payloadID := e.nextPayloadID()
+	e.payloads[payloadID.String()] = built
+
+	return payloadID, nil
+}
+
+func (p *builtPayload) blockHash() (eth.Hash, error) {
+	switch {
+	case p.parentBeaconBlockRoot != nil:
+		return (&services.NewPayloadV3Args{ExecutionPayload: *p.payload, ExpectedBlobVersionedHashes: []eth.Hash{}, ParentBeaconBlockRoot: p.parentBeaconBlockRoot}).ComputeBlockHash()
+	case p.payload.Withdrawals != nil:
+		return p.v2().ComputeBlockHash()
+	default:
+		return p.v1().ComputeBlockHash()
+	}
+}
+
+// reply returns the `engine_getPayload` reply of `version` for the payload.
+func (p *builtPayload) reply(version payloadVersion) interface{} {
+	switch version {
+	case payloadV1:
+		return p.v1()
+	case payloadV2:
+		return &services.GetPayloadV2Reply{ExecutionPayload: *p.v2(), BlockValue: services.BigInt{}}
+	default:
+		return &services.GetPayloadV3Reply{
+			ExecutionPayload: *p.payload,
+			BlockValue:       services.BigInt{},
+			BlobsBundle:      services.BlobsBundleV1Args{Commitments: []eth.Hex{}, Proofs: []eth.Hex{}, Blobs: []eth.Hex{}},
+		}
+	}
+}
+
+func (p *builtPayload) v1() *services.ExecutionPayloadV1Args {
+	payload := p.payload
+	return &services.ExecutionPayloadV1Args{
+		ParentHash:    payload.ParentHash,
+		FeeRecipient:  payload.FeeRecipient,
+		StateRoot:     payload.StateRoot,
+		ReceiptsRoot:  payload.ReceiptsRoot,
+		LogsBloom:     payload.LogsBloom,
+		PrevRandao:    payload.PrevRandao,
+		BlockNumber:   payload.BlockNumber,
+		GasLimit:      payload.GasLimit,
+		GasUsed:       payload.GasUsed,
+		Timestamp:     payload.Timestamp,
+		ExtraData:     payload.ExtraData,
+		BaseFeePerGas: payload.BaseFeePerGas,
+		BlockHash:     payload.BlockHash,
+		Transactions:  payload.Transactions,
+	}
+}
+
+func (p *builtPayload) v2() *services.ExecutionPayloadV2Args {
+	payload := p.payload
+	return &services.ExecutionPayloadV2Args{
+		ParentHash:    payload.ParentHash,
+		FeeRecipient:  payload.FeeRecipient,
+		StateRoot:     payload.StateRoot,
+		ReceiptsRoot:  payload.ReceiptsRoot,
+		LogsBloom:     payload.LogsBloom,
+		PrevRandao:    payload.PrevRandao,
+		BlockNumber:   payload.BlockNumber,
+		GasLimit:      payload.GasLimit,
+		GasUsed:       payload.GasUsed,
+		Timestamp:     payload.Timestamp,
+		ExtraData:     payload.ExtraData,
+		BaseFeePerGas: payload.BaseFeePerGas,
+		BlockHash:     payload.BlockHash,
+		Transactions:  payload.Transactions,
+		Withdrawals:   payload.Withdrawals,
+	}
+}
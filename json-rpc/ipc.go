@@ -0,0 +1,151 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// ServeIPC serves the JSON-RPC services over a unix domain socket created at `path`, like
+// the IPC endpoint of geth. Messages are JSON values read one after the other, each response
+// and notification is written followed by a newline. As with geth, the socket is protected by
+// file permissions only, Engine API calls require no token on it.
+func (s *Server) ServeIPC(path string) {
+	zlog.Info("listening & serving IPC content", zap.String("ipc_path", path))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0751); err != nil {
+		s.Shutdown(fmt.Errorf("unable to create IPC socket directory: %w", err))
+		return
+	}
+
+	// A socket left behind by a previous process would prevent listening
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		s.Shutdown(fmt.Errorf("unable to remove stale IPC socket %q: %w", path, err))
+		return
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		s.Shutdown(fmt.Errorf("failed listening IPC %q: %w", path, err))
+		return
+	}
+
+	// Only the owner may connect, the socket grants full access to the Engine API
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		s.Shutdown(fmt.Errorf("unable to restrict IPC socket %q permissions: %w", path, err))
+		return
+	}
+
+	ipc := &ipcListener{next: s.rpcHandler, listener: listener, connections: map[net.Conn]bool{}}
+	s.OnTerminating(func(_ error) {
+		ipc.Close()
+	})
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !s.IsTerminating() {
+				s.Shutdown(fmt.Errorf("failed accepting IPC connection: %w", err))
+			}
+			break
+		}
+
+		go ipc.serve(conn)
+	}
+
+	zlog.Info("IPC server terminated")
+}
+
+type ipcListener struct {
+	next     http.Handler
+	listener net.Listener
+
+	lock        sync.Mutex
+	connections map[net.Conn]bool
+}
+
+func (l *ipcListener) serve(conn net.Conn) {
+	l.track(conn, true)
+	defer l.track(conn, false)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, "POST", "/", nil)
+	if err != nil {
+		zlog.Error("unable to create IPC request template", zap.Error(err))
+		return
+	}
+
+	writeLock := sync.Mutex{}
+	connection := &rpcConnection{
+		next:          l.next,
+		request:       request,
+		authenticated: true,
+		send: func(message []byte) error {
+			writeLock.Lock()
+			defer writeLock.Unlock()
+
+			_, err := conn.Write(append(message, '\n'))
+			return err
+		},
+	}
+
+	zlog.Debug("IPC connection opened")
+	decoder := json.NewDecoder(conn)
+	err = connection.serve(func() ([]byte, error) {
+		var message json.RawMessage
+		if err := decoder.Decode(&message); err != nil {
+			return nil, err
+		}
+
+		return message, nil
+	})
+	zlog.Debug("IPC connection closed", zap.Error(err))
+}
+
+func (l *ipcListener) track(conn net.Conn, active bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if active {
+		l.connections[conn] = true
+	} else {
+		delete(l.connections, conn)
+	}
+}
+
+// Close stops accepting connections and closes the active ones.
+func (l *ipcListener) Close() {
+	l.listener.Close()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for conn := range l.connections {
+		conn.Close()
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/shutter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestServer_ServeIPC(t *testing.T) {
+	// Echoes the method of the call as its result, calls without id are notifications
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		call := gjson.ParseBytes(body)
+		if !call.Get("id").Exists() {
+			return
+		}
+
+		w.Write([]byte(`{"jsonrpc":"2.0","result":"` + call.Get("method").String() + `","id":` + call.Get("id").Raw + `}`))
+	})
+
+	path := filepath.Join(t.TempDir(), "proxy.ipc")
+	server := &Server{Shutter: shutter.New(), rpcHandler: next}
+	go server.ServeIPC(path)
+	defer server.Shutdown(nil)
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		var err error
+		conn, err = net.Dial("unix", path)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	defer conn.Close()
+
+	// Permissions are restricted right after listening, the dial above may have won the race
+	assert.Eventually(t, func() bool {
+		info, err := os.Stat(path)
+		return err == nil && info.Mode().Perm() == 0600
+	}, 5*time.Second, 10*time.Millisecond, "only the owner may connect to the socket")
+
+	// Messages are not required to be newline delimited, responses always are
+	_, err := conn.Write([]byte(`{"jsonrpc":"2.0","method":"engine_exchangeCapabilities","id":1}{"jsonrpc":"2.0","method":"eth_chainId"}` + "\n"))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte(`{"jsonrpc":"2.0","method":"eth_chainId","id":2}` + "\n"))
+	require.NoError(t, err)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	var responses []string
+	for i := 0; i < 2; i++ {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+
+		responses = append(responses, line)
+	}
+
+	assert.ElementsMatch(t, []string{
+		`{"jsonrpc":"2.0","result":"engine_exchangeCapabilities","id":1}` + "\n",
+		`{"jsonrpc":"2.0","result":"eth_chainId","id":2}` + "\n",
+	}, responses)
+}
@@ -0,0 +1,263 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package journal keeps an append-only record of the Engine API calls forwarded to the
+// upstream execution nodes so they can be replayed later against another node.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	ethrpc "github.com/streamingfast/eth-go/rpc"
+	"github.com/streamingfast/shutter"
+	"go.uber.org/zap"
+)
+
+const (
+	// segmentMaxEntries is the number of entries after which the current segment is written
+	segmentMaxEntries = 256
+
+	// segmentFlushInterval is the maximum amount of time an entry stays in memory before its
+	// segment is written, it's the amount of entries that could be lost on a crash.
+	segmentFlushInterval = 15 * time.Second
+
+	segmentWriteTimeout = 30 * time.Second
+)
+
+// Entry is a single Engine API call, `Params` is the JSON encoded array of params exactly as
+// they were sent to the upstreams.
+type Entry struct {
+	Time   time.Time       `json:"time"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// Writer appends entries to the journal. Entries are grouped in segments, each segment being
+// a compressed JSONL file named after the time of its first entry so that segments sort in
+// the order they were written, even across restarts.
+type Writer struct {
+	*shutter.Shutter
+
+	store dstore.Store
+
+	lock         sync.Mutex
+	segment      []*Entry
+	segmentStart time.Time
+
+	// writes tracks the segments being written in the background, none is started once closed
+	writes sync.WaitGroup
+	closed bool
+}
+
+func NewWriter(storeURL string) (*Writer, error) {
+	store, err := newStore(storeURL)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		Shutter: shutter.New(),
+		store:   store,
+	}
+
+	w.OnTerminating(func(_ error) {
+		w.lock.Lock()
+		w.closed = true
+		w.lock.Unlock()
+
+		w.writes.Wait()
+		w.flush()
+	})
+
+	go w.flushPeriodically()
+
+	return w, nil
+}
+
+// Append adds a call to the journal, the params are encoded right away so the caller is free
+// to reuse them.
+func (w *Writer) Append(method string, params []interface{}) error {
+	encoded, err := ethrpc.MarshalJSONRPC(params)
+	if err != nil {
+		return fmt.Errorf("encode params: %w", err)
+	}
+
+	w.lock.Lock()
+	now := time.Now().UTC()
+	if len(w.segment) == 0 {
+		w.segmentStart = now
+	}
+
+	w.segment = append(w.segment, &Entry{Time: now, Method: method, Params: encoded})
+
+	var full []*Entry
+	var start time.Time
+	if len(w.segment) >= segmentMaxEntries && !w.closed {
+		full, start = w.takeSegment()
+		w.writes.Add(1)
+	}
+	w.lock.Unlock()
+
+	// The segment is written in the background, the caller is on the Engine API request path
+	if full != nil {
+		go func() {
+			defer w.writes.Done()
+			w.writeSegment(full, start)
+		}()
+	}
+
+	return nil
+}
+
+func (w *Writer) flushPeriodically() {
+	ticker := time.NewTicker(segmentFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.Terminating():
+			return
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+// flush writes the current segment if it has any entry.
+func (w *Writer) flush() {
+	w.lock.Lock()
+	segment, start := w.takeSegment()
+	w.lock.Unlock()
+
+	if segment != nil {
+		w.writeSegment(segment, start)
+	}
+}
+
+// takeSegment swaps the current segment for an empty one and returns it along with its start
+// time, it must be called with the lock held.
+func (w *Writer) takeSegment() ([]*Entry, time.Time) {
+	segment, start := w.segment, w.segmentStart
+	w.segment = nil
+
+	return segment, start
+}
+
+// writeSegment writes `segment` to the store, it's called without the lock held so appending
+// entries is never delayed by the store. A segment that can't be written is logged and
+// dropped, the journal must never block the Engine API.
+func (w *Writer) writeSegment(segment []*Entry, start time.Time) {
+	buffer := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(buffer)
+	for _, entry := range segment {
+		if err := encoder.Encode(entry); err != nil {
+			zlog.Error("unable to encode journal entry", zap.String("method", entry.Method), zap.Error(err))
+		}
+	}
+
+	name := segmentName(start)
+	count := len(segment)
+
+	ctx, cancel := context.WithTimeout(context.Background(), segmentWriteTimeout)
+	defer cancel()
+
+	if err := w.store.WriteObject(ctx, name, buffer); err != nil {
+		zlog.Error("unable to write journal segment, entries are lost", zap.String("segment", name), zap.Int("entry_count", count), zap.Error(err))
+		return
+	}
+
+	zlog.Debug("journal segment written", zap.String("segment", name), zap.Int("entry_count", count))
+}
+
+// Read calls `f` for each entry of the journal in the order they were appended, starting with
+// the first segment started at or after `since`, a zero `since` reads the whole journal.
+// Iteration stops at the first error returned by `f`, which is returned as is.
+func Read(ctx context.Context, storeURL string, since time.Time, f func(entry *Entry) error) error {
+	store, err := newStore(storeURL)
+	if err != nil {
+		return err
+	}
+
+	// The zero time can't be represented in nanoseconds since epoch, walk from the start instead
+	startAfter := ""
+	if !since.IsZero() {
+		startAfter = segmentName(since)
+	}
+
+	var names []string
+	err = store.WalkFrom(ctx, "", startAfter, func(filename string) error {
+		names = append(names, filename)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("list journal segments: %w", err)
+	}
+
+	for _, name := range names {
+		if err := readSegment(ctx, store, name, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readSegment(ctx context.Context, store dstore.Store, name string, f func(entry *Entry) error) error {
+	reader, err := store.OpenObject(ctx, name)
+	if err != nil {
+		return fmt.Errorf("open journal segment %q: %w", name, err)
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	// Execution payloads easily exceed the default token size of the scanner
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return fmt.Errorf("decode journal segment %q entry: %w", name, err)
+		}
+
+		if err := f(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read journal segment %q: %w", name, err)
+	}
+
+	return nil
+}
+
+func newStore(storeURL string) (dstore.Store, error) {
+	store, err := dstore.NewJSONLStore(storeURL)
+	if err != nil {
+		return nil, fmt.Errorf("new journal store %q: %w", storeURL, err)
+	}
+
+	return store, nil
+}
+
+func segmentName(start time.Time) string {
+	return fmt.Sprintf("%020d", start.UnixNano())
+}
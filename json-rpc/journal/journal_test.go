@@ -0,0 +1,108 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/eth-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter_Read(t *testing.T) {
+	ctx := context.Background()
+	storeURL := "file://" + t.TempDir()
+
+	writer, err := NewWriter(storeURL)
+	require.NoError(t, err)
+
+	// One more than a segment so the journal spans two segments
+	for i := 0; i <= segmentMaxEntries; i++ {
+		state := map[string]interface{}{"headBlockHash": eth.MustNewHash(fmt.Sprintf("%064x", i))}
+		require.NoError(t, writer.Append("engine_forkchoiceUpdatedV2", []interface{}{state, nil}))
+	}
+	writer.Shutdown(nil)
+
+	var entries []*Entry
+	require.NoError(t, Read(ctx, storeURL, time.Time{}, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+
+	require.Len(t, entries, segmentMaxEntries+1)
+	for i, entry := range entries {
+		assert.Equal(t, "engine_forkchoiceUpdatedV2", entry.Method)
+		assert.JSONEq(t, fmt.Sprintf(`[{"headBlockHash":"0x%064x"},null]`, i), string(entry.Params))
+	}
+
+	var lastOnly []*Entry
+	require.NoError(t, Read(ctx, storeURL, entries[segmentMaxEntries].Time, func(entry *Entry) error {
+		lastOnly = append(lastOnly, entry)
+		return nil
+	}))
+	assert.Len(t, lastOnly, 1)
+}
+
+func TestRead_ZeroSince(t *testing.T) {
+	ctx := context.Background()
+	storeURL := "file://" + t.TempDir()
+
+	store, err := newStore(storeURL)
+	require.NoError(t, err)
+
+	// Oldest segment name possible, it must be read when no starting point is given
+	epochEntry := `{"time":"1970-01-01T00:00:00Z","method":"engine_newPayloadV1","params":[{"blockHash":"0x01"}]}` + "\n"
+	require.NoError(t, store.WriteObject(ctx, segmentName(time.Unix(0, 0)), strings.NewReader(epochEntry)))
+
+	writer, err := NewWriter(storeURL)
+	require.NoError(t, err)
+	require.NoError(t, writer.Append("engine_newPayloadV1", []interface{}{json.RawMessage(`{"blockHash":"0x02"}`)}))
+	writer.Shutdown(nil)
+
+	var entries []*Entry
+	require.NoError(t, Read(ctx, storeURL, time.Time{}, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+
+	require.Len(t, entries, 2)
+	assert.JSONEq(t, `[{"blockHash":"0x01"}]`, string(entries[0].Params))
+	assert.JSONEq(t, `[{"blockHash":"0x02"}]`, string(entries[1].Params))
+}
+
+func TestWriter_AppendRawParams(t *testing.T) {
+	storeURL := "file://" + t.TempDir()
+
+	writer, err := NewWriter(storeURL)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Append("engine_newPayloadV1", []interface{}{json.RawMessage(`{"blockHash":"0x01"}`)}))
+	writer.Shutdown(nil)
+
+	var entries []*Entry
+	require.NoError(t, Read(context.Background(), storeURL, time.Time{}, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+
+	require.Len(t, entries, 1)
+	assert.JSONEq(t, `[{"blockHash":"0x01"}]`, string(entries[0].Params))
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jwtauth implements the JWT authentication scheme defined by the Engine API
+// specification, tokens are signed with HS256 using a 32 bytes shared secret and carry
+// an `iat` claim that must be close to the current time.
+package jwtauth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// IssuedAtTolerance is the maximum drift accepted between the `iat` claim of a token and
+// the current time, as mandated by the Engine API specification.
+const IssuedAtTolerance = 60 * time.Second
+
+const secretLength = 32
+
+// LoadSecret reads an hex encoded secret, optionally prefixed with `0x`, from the file
+// at `path`.
+func LoadSecret(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read secret file: %w", err)
+	}
+
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(content)), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex secret in %q: %w", path, err)
+	}
+
+	if len(secret) != secretLength {
+		return nil, fmt.Errorf("invalid secret in %q, expected %d bytes but got %d", path, secretLength, len(secret))
+	}
+
+	return secret, nil
+}
+
+// LoadOrGenerateSecret works like LoadSecret but generates a new random secret and writes
+// it to `path` if the file does not exist yet, like execution clients do.
+func LoadOrGenerateSecret(path string) (secret []byte, generated bool, err error) {
+	secret, err = LoadSecret(path)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return secret, false, err
+	}
+
+	secret = make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, false, fmt.Errorf("generate secret: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, false, fmt.Errorf("create secret directory: %w", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(hex.EncodeToString(secret)), 0600); err != nil {
+		return nil, false, fmt.Errorf("write secret file: %w", err)
+	}
+
+	return secret, true, nil
+}
+
+// NewToken mints a token signed with `secret` and issued at `now`.
+func NewToken(secret []byte, now time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		IssuedAt: jwt.NewNumericDate(now),
+	})
+
+	return token.SignedString(secret)
+}
+
+// ValidateToken checks that `token` is signed with HS256 using `secret` and that its
+// `iat` claim is within IssuedAtTolerance of `now`.
+func ValidateToken(secret []byte, token string, now time.Time) error {
+	claims := jwt.RegisteredClaims{}
+
+	// Claims validation is disabled because `RegisteredClaims` refuses an `iat` in the
+	// future, we check it ourself allowing for drift in both directions.
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(_ *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return fmt.Errorf("parse token: %w", err)
+	}
+
+	if !parsed.Valid {
+		return fmt.Errorf("invalid token")
+	}
+
+	if claims.IssuedAt == nil {
+		return fmt.Errorf("missing issued-at claim")
+	}
+
+	drift := now.Sub(claims.IssuedAt.Time)
+	if drift > IssuedAtTolerance || drift < -IssuedAtTolerance {
+		return fmt.Errorf("stale token, issued-at %s is more than %s away from now", claims.IssuedAt.Time, IssuedAtTolerance)
+	}
+
+	return nil
+}
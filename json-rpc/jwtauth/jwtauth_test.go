@@ -0,0 +1,68 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwtauth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestValidateToken(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	sign := func(method jwt.SigningMethod, secret interface{}, claims jwt.Claims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(secret)
+		require.NoError(t, err)
+
+		return token
+	}
+
+	issuedAt := func(at time.Time) jwt.Claims {
+		return jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(at)}
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		expectedErr bool
+	}{
+		{"valid", sign(jwt.SigningMethodHS256, testSecret, issuedAt(now)), false},
+		{"valid drift past", sign(jwt.SigningMethodHS256, testSecret, issuedAt(now.Add(-59*time.Second))), false},
+		{"valid drift future", sign(jwt.SigningMethodHS256, testSecret, issuedAt(now.Add(59*time.Second))), false},
+		{"stale past", sign(jwt.SigningMethodHS256, testSecret, issuedAt(now.Add(-61*time.Second))), true},
+		{"stale future", sign(jwt.SigningMethodHS256, testSecret, issuedAt(now.Add(61*time.Second))), true},
+		{"missing iat", sign(jwt.SigningMethodHS256, testSecret, jwt.RegisteredClaims{}), true},
+		{"wrong secret", sign(jwt.SigningMethodHS256, []byte("another secret of thirty two b.."), issuedAt(now)), true},
+		{"wrong method", sign(jwt.SigningMethodHS512, testSecret, issuedAt(now)), true},
+		{"garbage", "not.a.token", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateToken(testSecret, test.token, now)
+			if test.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/streamingfast/logging"
+	"github.com/tidwall/gjson"
+	"go.uber.org/zap"
+)
+
+// forwardedNamespaces are the namespaces whose calls are forwarded to an upstream when the
+// proxy does not serve them itself
+var forwardedNamespaces = []string{"eth_", "net_", "web3_"}
+
+// passthroughHandler forwards verbatim each `eth`, `net` and `web3` call that is not served
+// by `local` to the read endpoint of the upstream with the highest head that is not syncing
+// and answers with the upstream's response as is. Only the few methods registered on `local`
+// are handled by the proxy, they are the ones depending on the proxy's own state like
+// subscriptions.
+//
+// Forwarded calls don't go through the hooks of `local`.
+type passthroughHandler struct {
+	local *rpc.Server
+	heads *upstream.HeadTracker
+}
+
+func newPassthroughHandler(local *rpc.Server, heads *upstream.HeadTracker) *passthroughHandler {
+	return &passthroughHandler{
+		local: local,
+		heads: heads,
+	}
+}
+
+func (h *passthroughHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "unable to read request body", http.StatusBadRequest)
+		return
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	method := gjson.GetBytes(body, "method").String()
+	if !isForwardedMethod(method) || h.local.HasMethod(method) {
+		h.local.ServeHTTP(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	zlogger := logging.Logger(ctx, zlog)
+	id := []byte(gjson.GetBytes(body, "id").Raw)
+
+	node := h.heads.Best()
+	if node == nil {
+		zlogger.Info("no synced upstream available to forward call", zap.String("method", method))
+		writeJSONRPCErrorResponse(w, id, json2.E_SERVER, "no synced upstream execution node available")
+		return
+	}
+
+	zlogger.Debug("forwarding call", zap.String("method", method), zap.Stringer("upstream", node))
+	response, err := node.Forward(ctx, body)
+	if err != nil {
+		zlogger.Warn("forwarded call failed", zap.String("method", method), zap.Stringer("upstream", node), zap.Error(err))
+		writeJSONRPCErrorResponse(w, id, json2.E_SERVER, "upstream execution node call failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(response)
+}
+
+func isForwardedMethod(method string) bool {
+	for _, namespace := range forwardedNamespaces {
+		if strings.HasPrefix(method, namespace) {
+			return true
+		}
+	}
+
+	return false
+}
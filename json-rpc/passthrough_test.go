@@ -0,0 +1,87 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/streamingfast/geth-proxy/config"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestPassthroughHandler(t *testing.T) {
+	var forwarded []string
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch method := gjson.GetBytes(body, "method").String(); method {
+		case "eth_syncing":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":false}`)
+		case "eth_blockNumber":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x10"}`)
+		default:
+			forwarded = append(forwarded, string(body))
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"upstream %s"}`, gjson.GetBytes(body, "id").Raw, method)
+		}
+	}))
+	defer node.Close()
+
+	rpc.MethodSeparator = "_"
+	local := rpc.NewServer()
+	local.RegisterCodec(services.NewEthereumCodec(), "application/json")
+	chainConfig, err := config.NetworkNameToChainConfig("goerli")
+	require.NoError(t, err)
+	require.NoError(t, local.RegisterService(services.NewEthService(chainConfig, nil), "eth"))
+	require.NoError(t, local.RegisterService(services.NewNetService(chainConfig), "net"))
+
+	heads := upstream.NewHeadTracker([]*upstream.Client{upstream.NewClient(node.URL, nil)})
+	handler := newPassthroughHandler(local, heads)
+
+	serve := func(body string) string {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(recorder, request)
+
+		return strings.TrimSpace(recorder.Body.String())
+	}
+
+	assert.Equal(t, `{"error":{"code":-32000,"message":"no synced upstream execution node available"},"id":"a","jsonrpc":"2.0"}`, serve(`{"jsonrpc":"2.0","method":"eth_getBalance","params":["0x0000000000000000000000000000000000000001","latest"],"id":"a"}`), "not polled yet")
+
+	heads.Poll(context.Background())
+
+	assert.Equal(t, `{"jsonrpc":"2.0","id":"b","result":"upstream eth_getBalance"}`, serve(`{"jsonrpc":"2.0","method":"eth_getBalance","params":["0x0000000000000000000000000000000000000001","latest"],"id":"b"}`))
+	assert.Equal(t, `{"jsonrpc":"2.0","id":3,"result":"upstream web3_clientVersion"}`, serve(`{"jsonrpc":"2.0","method":"web3_clientVersion","id":3}`))
+	assert.Equal(t, []string{
+		`{"jsonrpc":"2.0","method":"eth_getBalance","params":["0x0000000000000000000000000000000000000001","latest"],"id":"b"}`,
+		`{"jsonrpc":"2.0","method":"web3_clientVersion","id":3}`,
+	}, forwarded, "calls are forwarded verbatim")
+
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"0x5","id":4}`, serve(`{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":4}`), "served locally")
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"5","id":4}`, serve(`{"jsonrpc":"2.0","method":"net_version","params":[],"id":4}`), "served locally")
+	assert.Contains(t, serve(`{"jsonrpc":"2.0","method":"engine_getPayloadV1","params":["0x01"],"id":5}`), `can't find service`, "engine calls are never forwarded")
+	assert.Len(t, forwarded, 2)
+}
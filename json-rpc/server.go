@@ -21,8 +21,11 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/gorilla/rpc/v2"
 	"github.com/streamingfast/dhttp"
+	"github.com/streamingfast/geth-proxy/json-rpc/divergence"
 	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
 	"github.com/streamingfast/logging"
 	"github.com/streamingfast/shutter"
 	"go.uber.org/zap"
@@ -35,12 +38,20 @@ type Server struct {
 	httpServer     *http.Server
 	httpListenAddr string
 	mux            *mux.Router
+
+	// rpcHandler serves JSON-RPC requests without any of the HTTP middlewares, for the
+	// other transports
+	rpcHandler http.Handler
 }
 
 func NewServer(
 	httpListenAddr string,
 	isReady func() bool,
 	serviceHandlers []services.ServiceHandler,
+	jwtSecret []byte,
+	divergences *divergence.Store,
+	maxBatchSize int,
+	heads *upstream.HeadTracker,
 ) (*Server, error) {
 	router := mux.NewRouter()
 	srv := &Server{
@@ -71,9 +82,19 @@ func NewServer(
 	coreRouter.Use(dhttp.NewOpenCensusMiddleware())
 	coreRouter.Use(dhttp.NewAddTraceIDHeaderMiddleware(zlog))
 
+	// Debug endpoints
+	if divergences != nil {
+		registerDivergenceRoutes(coreRouter, divergences)
+	}
+
 	rpcRouter := coreRouter.PathPrefix("/").Subrouter()
 	rpcRouter.Use(forceContentTypeApplicationJSON)
 
+	// Engine API authentication, disabled when no secret is configured
+	if len(jwtSecret) > 0 {
+		rpcRouter.Use(newEngineAuthMiddleware(jwtSecret))
+	}
+
 	rpc.MethodSeparator = "_"
 	rpcServer := rpc.NewServer()
 	rpcServer.RegisterCodec(services.NewEthereumCodec(), "application/json")
@@ -92,16 +113,32 @@ func NewServer(
 		}
 	}
 
+	// Batches are split in individual calls served by `rpcServer`, read calls it does not
+	// serve are forwarded to an upstream when `heads` is configured
+	var callHandler http.Handler = rpcServer
+	if heads != nil {
+		callHandler = newPassthroughHandler(rpcServer, heads)
+	}
+
+	rpcHandler := newBatchHandler(callHandler, maxBatchSize)
+	srv.rpcHandler = rpcHandler
+
 	// The ingress forwards the full path `/call` to us, it does not strip the paths so we need to handle it directly ourself
-	rpcRouter.Path("/call").Methods("POST").Handler(rpcServer)
-	rpcRouter.Path("/call/{token}").Methods("POST").Handler(rpcServer)
+	rpcRouter.Path("/call").Methods("POST").Handler(rpcHandler)
+	rpcRouter.Path("/call/{token}").Methods("POST").Handler(rpcHandler)
 
 	// The ingress forwards the full path `/json-rpc` to us, it does not strip the paths so we need to handle it directly ourself
-	rpcRouter.Path("/json-rpc").Methods("POST").Handler(rpcServer)
-	rpcRouter.Path("/json-rpc/{token}").Methods("POST").Handler(rpcServer)
+	rpcRouter.Path("/json-rpc").Methods("POST").Handler(rpcHandler)
+	rpcRouter.Path("/json-rpc/{token}").Methods("POST").Handler(rpcHandler)
 
-	rpcRouter.Path("/").Methods("POST").Handler(rpcServer)
-	rpcRouter.Path("/{token}").Methods("POST").Handler(rpcServer)
+	rpcRouter.Path("/").Methods("POST").Handler(rpcHandler)
+	rpcRouter.Path("/{token}").Methods("POST").Handler(rpcHandler)
+
+	// WebSocket connections are accepted on the same paths, each message is served by `rpcHandler`
+	websocketHandler := newWebsocketHandler(rpcHandler, jwtSecret)
+	for _, path := range []string{"/call", "/call/{token}", "/json-rpc", "/json-rpc/{token}", "/", "/{token}"} {
+		rpcRouter.Path(path).Methods("GET").HeadersRegexp("Upgrade", "(?i)^websocket$").Handler(websocketHandler)
+	}
 
 	srv.OnTerminating(func(_ error) {
 		zlog.Info("gracefully shutting down http server, draining connections")
@@ -113,6 +150,8 @@ func NewServer(
 
 			srv.httpServer.Shutdown(ctx)
 		}
+
+		websocketHandler.Close()
 	})
 
 	return srv, nil
@@ -1,11 +1,16 @@
 package services
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 
+	gorillarpc "github.com/gorilla/rpc/v2"
 	"github.com/gorilla/rpc/v2/json2"
 	"github.com/streamingfast/eth-go/rpc"
+	"github.com/tidwall/gjson"
 )
 
 // NewEthereumCodec defines a `json2.Codec` that handles Ethereum rules like the output format
@@ -14,8 +19,58 @@ import (
 // The whole file is in `services` package because it's used in tests and also used in the
 // parent `jsonrpc` package which uses also the codec. By keeping it in `services`, we avoid
 // a cycle between `jsonrpc` -- requires --> `services` -- requires (via eth_call_test.go) --> `jsonrpc`.
-func NewEthereumCodec() *json2.Codec {
-	return json2.NewCustomCodec(json2.WithJSONEncoderFactory(EthereumJSONRPCEncoder))
+//
+// Positional params, the form used by all Ethereum clients, are decoded by the codec itself
+// into the fields of the method's argument struct, see `decodePositionalParams`. Params given
+// by name are left to `json2`.
+func NewEthereumCodec() *EthereumCodec {
+	return &EthereumCodec{
+		Codec: json2.NewCustomCodec(json2.WithJSONEncoderFactory(EthereumJSONRPCEncoder)),
+	}
+}
+
+type EthereumCodec struct {
+	*json2.Codec
+}
+
+func (c *EthereumCodec) NewRequest(r *http.Request) gorillarpc.CodecRequest {
+	// The body is read ahead of `json2` to keep the raw params of each request, it's handed
+	// back untouched. A read error is reported by `json2` as a parse error of the empty body.
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var params []gjson.Result
+	if message := gjson.ParseBytes(body); message.IsArray() {
+		for _, request := range message.Array() {
+			params = append(params, request.Get("params"))
+		}
+	} else {
+		params = append(params, message.Get("params"))
+	}
+
+	return &ethereumCodecRequest{
+		CodecRequest: c.Codec.NewRequest(r),
+		params:       params,
+	}
+}
+
+type ethereumCodecRequest struct {
+	gorillarpc.CodecRequest
+
+	params []gjson.Result
+}
+
+func (c *ethereumCodecRequest) ReadRequest(reqIdx int, args interface{}) error {
+	if reqIdx >= len(c.params) || !c.params[reqIdx].IsArray() {
+		return c.CodecRequest.ReadRequest(reqIdx, args)
+	}
+
+	if err := decodePositionalParams([]byte(c.params[reqIdx].Raw), args); err != nil {
+		return &json2.Error{Code: json2.E_BAD_PARAMS, Message: err.Error()}
+	}
+
+	return nil
 }
 
 type json2EncoderFunc func(v interface{}) error
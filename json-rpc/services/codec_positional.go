@@ -0,0 +1,128 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// decodePositionalParams decodes the JSON array `raw` into the struct `args` points to, each
+// array element being decoded into the exported field at the same position. Trailing params
+// may be omitted when their field is nilable (pointer, slice, map or interface), they are
+// left to their zero value. The errors follow the wording of go-ethereum's own decoding.
+//
+// An array with a single object element is decoded into `args` itself when its first field
+// can't receive an object, the param is then the whole argument, like the execution payload
+// of `engine_newPayloadV1`.
+func decodePositionalParams(raw []byte, args interface{}) error {
+	var params []json.RawMessage
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+
+	target := reflect.ValueOf(args).Elem()
+	if target.Kind() != reflect.Struct {
+		if len(params) != 1 {
+			return fmt.Errorf("expected 1 argument, got %d", len(params))
+		}
+
+		return decodePositionalParam(0, params[0], args)
+	}
+
+	fields := positionalFields(target.Type())
+	if len(params) == 1 && gjson.ParseBytes(params[0]).IsObject() && (len(fields) == 0 || !acceptsObject(target.Type().Field(fields[0]).Type)) {
+		return decodePositionalParam(0, params[0], args)
+	}
+
+	if len(params) > len(fields) {
+		return fmt.Errorf("too many arguments, want at most %d", len(fields))
+	}
+
+	for i, fieldIndex := range fields {
+		field := target.Field(fieldIndex)
+		if i >= len(params) {
+			if !isNilable(field.Kind()) {
+				return fmt.Errorf("missing value for required argument %d", i)
+			}
+
+			continue
+		}
+
+		if err := decodePositionalParam(i, params[i], field.Addr().Interface()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func decodePositionalParam(index int, param json.RawMessage, out interface{}) error {
+	if err := json.Unmarshal(param, out); err != nil {
+		return fmt.Errorf("invalid argument %d: %w", index, err)
+	}
+
+	return nil
+}
+
+// positionalFields returns the indices of the fields of `structType` receiving params, the
+// exported fields not ignored by JSON.
+func positionalFields(structType reflect.Type) (indices []int) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" || strings.Split(field.Tag.Get("json"), ",")[0] == "-" {
+			continue
+		}
+
+		indices = append(indices, i)
+	}
+
+	return
+}
+
+// acceptsObject returns true if a JSON object can be decoded in a value of type `t`, types
+// decoding themselves from text like `BigInt` are scalars even if they are structs.
+func acceptsObject(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Interface:
+		return true
+	}
+
+	return false
+}
+
+func isNilable(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"testing"
+
+	"github.com/streamingfast/eth-go"
+	ethrpc "github.com/streamingfast/eth-go/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockByNumberArgs are the params of `eth_getBlockByNumber`
+type blockByNumberArgs struct {
+	BlockRef            *ethrpc.BlockRef `json:"blockNrOrHash"`
+	IncludeTransactions bool             `json:"includeTransactions"`
+}
+
+func TestDecodePositionalParams(t *testing.T) {
+	t.Run("all params", func(t *testing.T) {
+		args := &ForkchoiceUpdatedV1Args{}
+		require.NoError(t, decodePositionalParams([]byte(`[{"headBlockHash":"0x01"},{"timestamp":"0x10"}]`), args))
+		assert.Equal(t, eth.MustNewHash("0x01"), args.ForkchoiceState.HeadBlockHash)
+		require.NotNil(t, args.PayloadAttributes)
+		assert.Equal(t, eth.Uint64(16), args.PayloadAttributes.Timestamp)
+	})
+
+	t.Run("optional trailing param omitted", func(t *testing.T) {
+		args := &ForkchoiceUpdatedV1Args{}
+		require.NoError(t, decodePositionalParams([]byte(`[{"headBlockHash":"0x01"}]`), args))
+		assert.Equal(t, eth.MustNewHash("0x01"), args.ForkchoiceState.HeadBlockHash)
+		assert.Nil(t, args.PayloadAttributes)
+	})
+
+	t.Run("scalars", func(t *testing.T) {
+		args := &blockByNumberArgs{}
+		require.NoError(t, decodePositionalParams([]byte(`["0x10",true]`), args))
+		number, ok := args.BlockRef.BlockNumber()
+		assert.True(t, ok)
+		assert.Equal(t, uint64(16), number)
+		assert.True(t, args.IncludeTransactions)
+	})
+
+	t.Run("single param is the whole argument", func(t *testing.T) {
+		args := &ExecutionPayloadV1Args{}
+		require.NoError(t, decodePositionalParams([]byte(`[{"blockHash":"0x02","blockNumber":"0x3"}]`), args))
+		assert.Equal(t, eth.MustNewHash("0x02"), args.BlockHash)
+		assert.Equal(t, eth.Uint64(3), args.BlockNumber)
+	})
+
+	t.Run("missing required param", func(t *testing.T) {
+		assert.EqualError(t, decodePositionalParams([]byte(`["0x10"]`), &blockByNumberArgs{}), "missing value for required argument 1")
+	})
+
+	t.Run("too many params", func(t *testing.T) {
+		assert.EqualError(t, decodePositionalParams([]byte(`["0x10",true,1]`), &blockByNumberArgs{}), "too many arguments, want at most 2")
+	})
+
+	t.Run("invalid param", func(t *testing.T) {
+		err := decodePositionalParams([]byte(`["0x10","yes"]`), &blockByNumberArgs{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid argument 1: ")
+	})
+}
@@ -14,13 +14,134 @@
 
 package services
 
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/streamingfast/geth-proxy/config"
+	"github.com/streamingfast/geth-proxy/json-rpc/divergence"
+	"github.com/streamingfast/geth-proxy/json-rpc/journal"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
+)
+
+// upstreamCallTimeout is the maximum amount of time we wait for a single upstream node
+// to answer, it follows the timeouts recommended by the Engine API specification.
+const upstreamCallTimeout = 8 * time.Second
+
 type EngineService struct {
+	chainConfig *config.ChainConfig
+	upstreams   []*upstream.Client
+
+	// builder is the upstream node receiving payload attributes, it's the only one
+	// building payloads on behalf of the consensus client.
+	builder *upstream.Client
+
+	// quorum decides which payload status is answered when upstreams disagree.
+	quorum QuorumPolicy
+
+	// divergences receives a report each time upstreams disagree on the validity of a
+	// payload, reports are not saved when nil.
+	divergences *divergence.Store
+
+	// journal records every accepted newPayload and forkchoiceUpdated call so they can be
+	// replayed later against another node, nothing is recorded when nil.
+	journal *journal.Writer
+
+	// forkchoice is the last forkchoice state accepted as VALID, it's sent to upstreams
+	// coming back so they resume following the chain right away.
+	forkchoice *lastForkchoice
+
+	// subscriptions is notified of each head accepted as VALID to feed `eth_subscribe`
+	// subscribers, heads are not published when nil.
+	subscriptions *Subscriptions
+
+	payloads     *payloadRegistry
+	capabilities *capabilitiesCache
+	health       *upstreamHealth
+	backlog      *upstreamBacklog
 }
 
-func NewEngineService() *EngineService {
-	return &EngineService{}
+func NewEngineService(chainConfig *config.ChainConfig, upstreams []*upstream.Client, builder *upstream.Client, quorum QuorumPolicy, divergences *divergence.Store, calls *journal.Writer, forkchoiceStatePath string, subscriptions *Subscriptions) *EngineService {
+	return &EngineService{
+		chainConfig:   chainConfig,
+		upstreams:     upstreams,
+		builder:       builder,
+		quorum:        quorum,
+		divergences:   divergences,
+		journal:       calls,
+		forkchoice:    newLastForkchoice(forkchoiceStatePath),
+		subscriptions: subscriptions,
+		payloads:      newPayloadRegistry(),
+		capabilities:  newCapabilitiesCache(),
+		health:        newUpstreamHealth(),
+		backlog:       newUpstreamBacklog(),
+	}
 }
 
 func (e *EngineService) Namespace() string {
 	return "engine"
 }
+
+// record retains the call in the backlog used to catch up lagging upstreams, the call's backlog
+// sequence is returned.
+func (e *EngineService) record(method string, params ...interface{}) uint64 {
+	return e.backlog.Append(method, params)
+}
+
+// journalCall appends the call to the journal if one is configured, once its merged `status`
+// is known. Calls rejected as INVALID are left out so a replay only follows the chain the
+// upstreams accepted. Failing to journal a call is logged but never fails the request, the
+// journal is a best effort facility.
+func (e *EngineService) journalCall(ctx context.Context, method string, status EnginePayloadStatus, params ...interface{}) {
+	if e.journal == nil {
+		return
+	}
+
+	if status == EnginePayloadStatusInvalid || status == EnginePayloadStatusInvalidBlockHash {
+		return
+	}
+
+	if err := e.journal.Append(method, params); err != nil {
+		logging.Logger(ctx, zlog).Warn("unable to record call in journal", zap.String("method", method), zap.Error(err))
+	}
+}
+
+type upstreamResponse struct {
+	upstream *upstream.Client
+	content  json.RawMessage
+	err      error
+}
+
+// fanOut sends the same JSON-RPC request concurrently to all upstream nodes and waits for
+// all of them to complete. The responses are returned in the same order as the upstreams.
+func (e *EngineService) fanOut(ctx context.Context, method string, params ...interface{}) []*upstreamResponse {
+	return e.fanOutWith(ctx, method, func(_ *upstream.Client) []interface{} { return params })
+}
+
+// fanOutWith is like fanOut but the params sent to each upstream are resolved by `paramsFor`,
+// for requests where some upstreams must receive a different payload than others.
+func (e *EngineService) fanOutWith(ctx context.Context, method string, paramsFor func(node *upstream.Client) []interface{}) []*upstreamResponse {
+	responses := make([]*upstreamResponse, len(e.upstreams))
+
+	wg := sync.WaitGroup{}
+	for i, node := range e.upstreams {
+		wg.Add(1)
+
+		go func(i int, node *upstream.Client) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, upstreamCallTimeout)
+			defer cancel()
+
+			content, err := node.DoRequest(ctx, method, paramsFor(node))
+			responses[i] = &upstreamResponse{upstream: node, content: content, err: err}
+		}(i, node)
+	}
+	wg.Wait()
+
+	return responses
+}
@@ -0,0 +1,224 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/streamingfast/eth-go"
+)
+
+// executionHeader is the RLP layout of an execution block header up to Cancun, the header
+// type of the go-ethereum version we depend on stops at London so we maintain our own.
+type executionHeader struct {
+	ParentHash       common.Hash
+	UncleHash        common.Hash
+	Coinbase         common.Address
+	Root             common.Hash
+	TxHash           common.Hash
+	ReceiptHash      common.Hash
+	Bloom            types.Bloom
+	Difficulty       *big.Int
+	Number           *big.Int
+	GasLimit         uint64
+	GasUsed          uint64
+	Time             uint64
+	Extra            []byte
+	MixDigest        common.Hash
+	Nonce            types.BlockNonce
+	BaseFee          *big.Int     `rlp:"optional"`
+	WithdrawalsHash  *common.Hash `rlp:"optional"`
+	BlobGasUsed      *uint64      `rlp:"optional"`
+	ExcessBlobGas    *uint64      `rlp:"optional"`
+	ParentBeaconRoot *common.Hash `rlp:"optional"`
+}
+
+// verifiablePayload is implemented by the params of every `engine_newPayload` version, it
+// rebuilds the header of the block from the payload fields.
+type verifiablePayload interface {
+	header() (*executionHeader, error)
+}
+
+// verifyBlockHash rebuilds the header of `payload` and compares its hash with `blockHash`,
+// it returns the status to answer right away when they differ and nil when they match.
+func verifyBlockHash(blockHash eth.Hash, payload verifiablePayload) *PayloadStatusV1Args {
+	header, err := payload.header()
+	if err != nil {
+		validationError := err.Error()
+		return &PayloadStatusV1Args{Status: EnginePayloadStatusInvalid, ValidationError: &validationError}
+	}
+
+	if computed := header.Hash(); !bytes.Equal(computed, blockHash) {
+		validationError := fmt.Sprintf("blockhash mismatch, want %s, got %s", blockHash, computed)
+		return &PayloadStatusV1Args{Status: EnginePayloadStatusInvalidBlockHash, ValidationError: &validationError}
+	}
+
+	return nil
+}
+
+// ComputeBlockHash returns the hash of the block header rebuilt from the payload fields, it's
+// the value the payload's `BlockHash` must have.
+func (p *ExecutionPayloadV1Args) ComputeBlockHash() (eth.Hash, error) {
+	return computeBlockHash(p)
+}
+
+// ComputeBlockHash returns the hash of the block header rebuilt from the payload fields, it's
+// the value the payload's `BlockHash` must have.
+func (p *ExecutionPayloadV2Args) ComputeBlockHash() (eth.Hash, error) {
+	return computeBlockHash(p)
+}
+
+// ComputeBlockHash returns the hash of the block header rebuilt from the payload fields and
+// the parent beacon block root, it's the value the payload's `BlockHash` must have.
+func (a *NewPayloadV3Args) ComputeBlockHash() (eth.Hash, error) {
+	return computeBlockHash(a)
+}
+
+func computeBlockHash(payload verifiablePayload) (eth.Hash, error) {
+	header, err := payload.header()
+	if err != nil {
+		return nil, err
+	}
+
+	return header.Hash(), nil
+}
+
+func (h *executionHeader) Hash() eth.Hash {
+	encoded, err := rlp.EncodeToBytes(h)
+	if err != nil {
+		// Only fails on unsupported types which would be a programming error
+		panic(fmt.Errorf("rlp encode execution header: %w", err))
+	}
+
+	return eth.Hash(crypto.Keccak256(encoded))
+}
+
+// newExecutionHeader rebuilds the header of the block described by the fields common to all
+// execution payload versions, the post-merge constant fields are filled in as well.
+func newExecutionHeader(
+	parentHash eth.Hash,
+	feeRecipient eth.Address,
+	stateRoot eth.Hash,
+	receiptsRoot eth.Hash,
+	logsBloom eth.Hex,
+	prevRandao eth.Hash,
+	blockNumber eth.Uint64,
+	gasLimit eth.Uint64,
+	gasUsed eth.Uint64,
+	timestamp eth.Uint64,
+	extraData eth.Hex,
+	baseFeePerGas BigInt,
+	transactions []eth.Hex,
+) (*executionHeader, error) {
+	if len(logsBloom) != types.BloomByteLength {
+		return nil, fmt.Errorf("invalid logsBloom length %d, expected %d", len(logsBloom), types.BloomByteLength)
+	}
+
+	return &executionHeader{
+		ParentHash:  common.BytesToHash(parentHash),
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    common.BytesToAddress(feeRecipient),
+		Root:        common.BytesToHash(stateRoot),
+		TxHash:      types.DeriveSha(rawTransactions(transactions), trie.NewStackTrie(nil)),
+		ReceiptHash: common.BytesToHash(receiptsRoot),
+		Bloom:       types.BytesToBloom(logsBloom),
+		Difficulty:  big.NewInt(0),
+		Number:      new(big.Int).SetUint64(uint64(blockNumber)),
+		GasLimit:    uint64(gasLimit),
+		GasUsed:     uint64(gasUsed),
+		Time:        uint64(timestamp),
+		Extra:       extraData,
+		MixDigest:   common.BytesToHash(prevRandao),
+		BaseFee:     baseFeePerGas.Int(),
+	}, nil
+}
+
+func (p *ExecutionPayloadV1Args) header() (*executionHeader, error) {
+	return newExecutionHeader(p.ParentHash, p.FeeRecipient, p.StateRoot, p.ReceiptsRoot, p.LogsBloom, p.PrevRandao, p.BlockNumber, p.GasLimit, p.GasUsed, p.Timestamp, p.ExtraData, p.BaseFeePerGas, p.Transactions)
+}
+
+func (p *ExecutionPayloadV2Args) header() (*executionHeader, error) {
+	header, err := newExecutionHeader(p.ParentHash, p.FeeRecipient, p.StateRoot, p.ReceiptsRoot, p.LogsBloom, p.PrevRandao, p.BlockNumber, p.GasLimit, p.GasUsed, p.Timestamp, p.ExtraData, p.BaseFeePerGas, p.Transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	// A V2 payload without withdrawals is a pre-Shanghai payload
+	if p.Withdrawals != nil {
+		header.WithdrawalsHash = withdrawalsHash(p.Withdrawals)
+	}
+
+	return header, nil
+}
+
+func (a *NewPayloadV3Args) header() (*executionHeader, error) {
+	p := &a.ExecutionPayload
+	header, err := newExecutionHeader(p.ParentHash, p.FeeRecipient, p.StateRoot, p.ReceiptsRoot, p.LogsBloom, p.PrevRandao, p.BlockNumber, p.GasLimit, p.GasUsed, p.Timestamp, p.ExtraData, p.BaseFeePerGas, p.Transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	blobGasUsed, excessBlobGas := uint64(p.BlobGasUsed), uint64(p.ExcessBlobGas)
+	parentBeaconRoot := common.BytesToHash(a.ParentBeaconBlockRoot)
+
+	header.WithdrawalsHash = withdrawalsHash(p.Withdrawals)
+	header.BlobGasUsed = &blobGasUsed
+	header.ExcessBlobGas = &excessBlobGas
+	header.ParentBeaconRoot = &parentBeaconRoot
+
+	return header, nil
+}
+
+func withdrawalsHash(withdrawals []WithdrawalV1Args) *common.Hash {
+	hash := types.DeriveSha(rlpWithdrawals(withdrawals), trie.NewStackTrie(nil))
+	return &hash
+}
+
+// rawTransactions are transactions already in their binary (envelope) encoding.
+type rawTransactions []eth.Hex
+
+func (t rawTransactions) Len() int { return len(t) }
+
+func (t rawTransactions) EncodeIndex(i int, w *bytes.Buffer) {
+	w.Write(t[i])
+}
+
+type rlpWithdrawal struct {
+	Index     uint64
+	Validator uint64
+	Address   common.Address
+	Amount    uint64
+}
+
+type rlpWithdrawals []WithdrawalV1Args
+
+func (w rlpWithdrawals) Len() int { return len(w) }
+
+func (w rlpWithdrawals) EncodeIndex(i int, buffer *bytes.Buffer) {
+	withdrawal := w[i]
+	rlp.Encode(buffer, &rlpWithdrawal{
+		Index:     uint64(withdrawal.Index),
+		Validator: uint64(withdrawal.ValidatorIndex),
+		Address:   common.BytesToAddress(withdrawal.Address),
+		Amount:    uint64(withdrawal.Amount),
+	})
+}
@@ -0,0 +1,205 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/streamingfast/eth-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyBlockHash(t *testing.T) {
+	transactions := types.Transactions{
+		types.NewTx(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(10), Gas: 21000, To: &common.Address{0x01}, Value: big.NewInt(1)}),
+		types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(5), Nonce: 2, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(20), Gas: 21000, To: &common.Address{0x02}}),
+	}
+
+	rawTransactions := make([]eth.Hex, len(transactions))
+	for i, transaction := range transactions {
+		raw, err := transaction.MarshalBinary()
+		require.NoError(t, err)
+		rawTransactions[i] = raw
+	}
+
+	reference := &types.Header{
+		ParentHash:  common.HexToHash("0x3b8fb240d288781d4aac94d3fd16809ee413bc99294a085798a589dae51ddd4a"),
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    common.HexToAddress("0xa94f5374fce5edbc8e2a8697c15331677e6ebf0b"),
+		Root:        common.HexToHash("0xca3149fa9e37db08d1cd49c9061db1002ef1cd58db2210f2115c8c989b2bdf45"),
+		TxHash:      types.DeriveSha(transactions, trie.NewStackTrie(nil)),
+		ReceiptHash: types.EmptyRootHash,
+		Bloom:       types.Bloom{0x01},
+		Difficulty:  big.NewInt(0),
+		Number:      big.NewInt(10),
+		GasLimit:    30_000_000,
+		GasUsed:     42_000,
+		Time:        1_700_000_000,
+		Extra:       []byte("proxy"),
+		MixDigest:   common.HexToHash("0x01"),
+		BaseFee:     big.NewInt(7),
+	}
+
+	payload := &ExecutionPayloadV1Args{
+		ParentHash:    reference.ParentHash.Bytes(),
+		FeeRecipient:  reference.Coinbase.Bytes(),
+		StateRoot:     reference.Root.Bytes(),
+		ReceiptsRoot:  reference.ReceiptHash.Bytes(),
+		LogsBloom:     reference.Bloom.Bytes(),
+		PrevRandao:    reference.MixDigest.Bytes(),
+		BlockNumber:   10,
+		GasLimit:      30_000_000,
+		GasUsed:       42_000,
+		Timestamp:     1_700_000_000,
+		ExtraData:     reference.Extra,
+		BaseFeePerGas: BigInt(*reference.BaseFee),
+		BlockHash:     reference.Hash().Bytes(),
+		Transactions:  rawTransactions,
+	}
+
+	assert.Nil(t, verifyBlockHash(payload.BlockHash, payload))
+
+	invalid := verifyBlockHash(eth.MustNewHash("0x0000000000000000000000000000000000000000000000000000000000000001"), payload)
+	require.NotNil(t, invalid)
+	assert.Equal(t, EnginePayloadStatusInvalidBlockHash, invalid.Status)
+
+	payload.LogsBloom = eth.MustNewHex("0x01")
+	invalid = verifyBlockHash(payload.BlockHash, payload)
+	require.NotNil(t, invalid)
+	assert.Equal(t, EnginePayloadStatusInvalid, invalid.Status)
+}
+
+func TestExecutionPayloadV2Header(t *testing.T) {
+	payload := &ExecutionPayloadV2Args{LogsBloom: make(eth.Hex, types.BloomByteLength)}
+
+	header, err := payload.header()
+	require.NoError(t, err)
+	assert.Nil(t, header.WithdrawalsHash, "pre-Shanghai payload")
+
+	payload.Withdrawals = []WithdrawalV1Args{}
+	header, err = payload.header()
+	require.NoError(t, err)
+	assert.Equal(t, types.EmptyRootHash, *header.WithdrawalsHash)
+}
+
+// headerFixture lists the header fields in the order mandated by EIP-1559, EIP-4895, EIP-4844
+// and EIP-4788. Every field holds a distinct value so an encoding that swaps two of them
+// produces a different hash.
+func headerFixture(t *testing.T) (payload *ExecutionPayloadV3Args, fields []interface{}) {
+	payload = &ExecutionPayloadV3Args{
+		ParentHash:    eth.MustNewHash("0x1111111111111111111111111111111111111111111111111111111111111111"),
+		FeeRecipient:  eth.MustNewAddress("0x2222222222222222222222222222222222222222"),
+		StateRoot:     eth.MustNewHash("0x3333333333333333333333333333333333333333333333333333333333333333"),
+		ReceiptsRoot:  eth.MustNewHash("0x4444444444444444444444444444444444444444444444444444444444444444"),
+		LogsBloom:     types.Bloom{0x05}.Bytes(),
+		PrevRandao:    eth.MustNewHash("0x6666666666666666666666666666666666666666666666666666666666666666"),
+		BlockNumber:   17_034_870,
+		GasLimit:      30_000_000,
+		GasUsed:       12_345_678,
+		Timestamp:     1_710_338_135,
+		ExtraData:     []byte("fixture"),
+		BaseFeePerGas: BigInt(*big.NewInt(17_000_000_000)),
+		Transactions:  []eth.Hex{},
+		Withdrawals: []WithdrawalV1Args{
+			{Index: 0, ValidatorIndex: 100, Address: eth.MustNewAddress("0x7777777777777777777777777777777777777777"), Amount: 1_000},
+			{Index: 1, ValidatorIndex: 200, Address: eth.MustNewAddress("0x8888888888888888888888888888888888888888"), Amount: 2_000},
+		},
+		BlobGasUsed:   393_216,
+		ExcessBlobGas: 79_691_776,
+	}
+
+	// The withdrawals trie is keyed by the RLP encoded index, the stack trie needs sorted keys
+	withdrawals := trie.NewStackTrie(nil)
+	for _, index := range []uint64{1, 0} {
+		withdrawal := payload.Withdrawals[index]
+		value, err := rlp.EncodeToBytes([]interface{}{uint64(withdrawal.Index), uint64(withdrawal.ValidatorIndex), []byte(withdrawal.Address), uint64(withdrawal.Amount)})
+		require.NoError(t, err)
+
+		key, err := rlp.EncodeToBytes(index)
+		require.NoError(t, err)
+		withdrawals.Update(key, value)
+	}
+
+	fields = []interface{}{
+		[]byte(payload.ParentHash),
+		types.EmptyUncleHash.Bytes(),
+		[]byte(payload.FeeRecipient),
+		[]byte(payload.StateRoot),
+		types.EmptyRootHash.Bytes(),
+		[]byte(payload.ReceiptsRoot),
+		[]byte(payload.LogsBloom),
+		uint64(0),
+		uint64(payload.BlockNumber),
+		uint64(payload.GasLimit),
+		uint64(payload.GasUsed),
+		uint64(payload.Timestamp),
+		[]byte(payload.ExtraData),
+		[]byte(payload.PrevRandao),
+		make([]byte, 8),
+		payload.BaseFeePerGas.Int(),
+		withdrawals.Hash().Bytes(),
+	}
+
+	return payload, fields
+}
+
+func rlpHash(t *testing.T, fields []interface{}) eth.Hash {
+	encoded, err := rlp.EncodeToBytes(fields)
+	require.NoError(t, err)
+
+	return crypto.Keccak256(encoded)
+}
+
+func TestComputeBlockHash_ShanghaiFieldOrder(t *testing.T) {
+	v3, fields := headerFixture(t)
+	payload := &ExecutionPayloadV2Args{
+		ParentHash:    v3.ParentHash,
+		FeeRecipient:  v3.FeeRecipient,
+		StateRoot:     v3.StateRoot,
+		ReceiptsRoot:  v3.ReceiptsRoot,
+		LogsBloom:     v3.LogsBloom,
+		PrevRandao:    v3.PrevRandao,
+		BlockNumber:   v3.BlockNumber,
+		GasLimit:      v3.GasLimit,
+		GasUsed:       v3.GasUsed,
+		Timestamp:     v3.Timestamp,
+		ExtraData:     v3.ExtraData,
+		BaseFeePerGas: v3.BaseFeePerGas,
+		Transactions:  v3.Transactions,
+		Withdrawals:   v3.Withdrawals,
+	}
+
+	computed, err := payload.ComputeBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, rlpHash(t, fields), computed)
+}
+
+func TestComputeBlockHash_CancunFieldOrder(t *testing.T) {
+	payload, fields := headerFixture(t)
+	parentBeaconBlockRoot := eth.MustNewHash("0x9999999999999999999999999999999999999999999999999999999999999999")
+
+	fields = append(fields, uint64(payload.BlobGasUsed), uint64(payload.ExcessBlobGas), []byte(parentBeaconBlockRoot))
+
+	computed, err := (&NewPayloadV3Args{ExecutionPayload: *payload, ParentBeaconBlockRoot: parentBeaconBlockRoot}).ComputeBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, rlpHash(t, fields), computed)
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/streamingfast/eth-go"
+	ethrpc "github.com/streamingfast/eth-go/rpc"
+	"github.com/streamingfast/geth-proxy/json-rpc/divergence"
+	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
+)
+
+// divergenceReportTimeout bounds the time spent querying client versions and writing a
+// divergence report, it's done in the background and never delays the consensus client.
+const divergenceReportTimeout = 30 * time.Second
+
+// detectDivergence checks if upstreams disagree on the validity of a payload, one of them
+// answering VALID while another answers INVALID. Such a split is either a client bug or a
+// corrupted database, it's logged, counted and reported to the divergence store if any.
+func (e *EngineService) detectDivergence(ctx context.Context, method string, blockHash eth.Hash, blockNumber eth.Uint64, votes payloadStatusVotes, params []interface{}) {
+	if !votes.diverging() {
+		return
+	}
+
+	zlogger := logging.Logger(ctx, zlog)
+	zlogger.Error("upstreams diverge on payload validity", zap.String("method", method), zap.Stringer("block_hash", blockHash), zap.Uint64("block_number", uint64(blockNumber)), zap.Array("votes", votes))
+	ExecutionDivergenceCount.Inc(method)
+
+	if e.divergences == nil {
+		return
+	}
+
+	report := &divergence.Report{
+		DetectedAt:  time.Now().UTC(),
+		Method:      method,
+		BlockHash:   blockHash.Pretty(),
+		BlockNumber: uint64(blockNumber),
+	}
+
+	if encoded, err := ethrpc.MarshalJSONRPC(params); err == nil {
+		report.Params = encoded
+	} else {
+		zlogger.Warn("unable to encode divergence params", zap.Error(err))
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), divergenceReportTimeout)
+		defer cancel()
+
+		for _, vote := range votes {
+			response := &divergence.UpstreamResponse{
+				Upstream:      vote.upstream.String(),
+				ClientVersion: clientVersion(ctx, vote),
+				Response:      vote.content,
+			}
+
+			if vote.err != nil {
+				response.Error = vote.err.Error()
+			}
+
+			report.Responses = append(report.Responses, response)
+		}
+
+		if err := e.divergences.Save(ctx, report); err != nil {
+			zlogger.Error("unable to save divergence report", zap.String("name", report.Name()), zap.Error(err))
+			return
+		}
+
+		zlogger.Info("divergence report saved", zap.String("name", report.Name()))
+	}()
+}
+
+// proxyClientVersion identifies the proxy to the upstreams when querying their version, the
+// specification requires the caller to send its own version along.
+var proxyClientVersion = &ClientVersionV1{Code: "SF", Name: "geth-proxy", Version: "v0.0.0", Commit: "00000000"}
+
+// ClientVersionV1 identifies an execution client as returned by `engine_getClientVersionV1`.
+type ClientVersionV1 struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+}
+
+func (v *ClientVersionV1) String() string {
+	return fmt.Sprintf("%s/%s-%s (%s)", v.Name, v.Version, v.Commit, v.Code)
+}
+
+// clientVersion returns the client version of the upstream that answered `vote`, the error
+// is returned instead if it can't be retrieved. The authenticated endpoint only serves the
+// `engine` and `eth` namespaces, so `engine_getClientVersionV1` is used and the upstreams
+// predating it are asked through `web3_clientVersion` which some clients serve there too.
+func clientVersion(ctx context.Context, vote *payloadStatusVote) string {
+	ctx, cancel := context.WithTimeout(ctx, upstreamCallTimeout)
+	defer cancel()
+
+	var versions []*ClientVersionV1
+	err := vote.upstream.Call(ctx, "engine_getClientVersionV1", []interface{}{proxyClientVersion}, &versions)
+
+	var errResponse *ethrpc.ErrResponse
+	if errors.As(err, &errResponse) && errResponse.Code == -32601 {
+		var version string
+		if err := vote.upstream.Call(ctx, "web3_clientVersion", nil, &version); err != nil {
+			return "unknown (" + err.Error() + ")"
+		}
+
+		return version
+	}
+
+	if err != nil {
+		return "unknown (" + err.Error() + ")"
+	}
+
+	// A multiplexer answers with the version of each client behind it
+	names := make([]string, len(versions))
+	for i, version := range versions {
+		names[i] = version.String()
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// diverging returns true if at least one upstream considers the payload VALID while another
+// one considers it INVALID.
+func (v payloadStatusVotes) diverging() bool {
+	valid, invalid := false, false
+	for _, vote := range v {
+		if vote.status == nil {
+			continue
+		}
+
+		switch vote.status.Status {
+		case EnginePayloadStatusValid:
+			valid = true
+		case EnginePayloadStatusInvalid, EnginePayloadStatusInvalidBlockHash:
+			invalid = true
+		}
+	}
+
+	return valid && invalid
+}
@@ -0,0 +1,44 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"errors"
+
+	"github.com/gorilla/rpc/v2/json2"
+	ethrpc "github.com/streamingfast/eth-go/rpc"
+)
+
+// Engine API specific error codes, see https://github.com/ethereum/execution-apis/blob/main/src/engine/common.md#errors
+const (
+	EngineErrUnknownPayload           json2.ErrorCode = -38001
+	EngineErrInvalidForkchoiceState   json2.ErrorCode = -38002
+	EngineErrInvalidPayloadAttributes json2.ErrorCode = -38003
+	EngineErrTooLargeRequest          json2.ErrorCode = -38004
+	EngineErrUnsupportedFork          json2.ErrorCode = -38005
+)
+
+var ErrUnknownPayload = &json2.Error{Code: EngineErrUnknownPayload, Message: "Unknown payload"}
+
+// toJSONRPCError converts an error returned by an upstream into the error returned to the
+// consensus client, JSON-RPC errors of the upstream are forwarded untouched.
+func toJSONRPCError(err error) error {
+	var errResponse *ethrpc.ErrResponse
+	if errors.As(err, &errResponse) {
+		return &json2.Error{Code: json2.ErrorCode(errResponse.Code), Message: errResponse.Message, Data: errResponse.Data}
+	}
+
+	return &json2.Error{Code: json2.E_SERVER, Message: err.Error()}
+}
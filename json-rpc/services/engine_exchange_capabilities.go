@@ -0,0 +1,168 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/rpc/v2"
+	ethrpc "github.com/streamingfast/eth-go/rpc"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
+)
+
+// legacyEngineMethods are the methods assumed to be supported by an upstream that predates
+// `engine_exchangeCapabilities` and answers it with a method not found error.
+var legacyEngineMethods = []string{
+	"engine_exchangeTransitionConfigurationV1",
+	"engine_forkchoiceUpdatedV1",
+	"engine_getPayloadV1",
+	"engine_newPayloadV1",
+}
+
+type ExchangeCapabilitiesArgs struct {
+	Capabilities []string `json:"capabilities"`
+}
+
+// ExchangeCapabilities answers with the Engine API methods that both the proxy and every
+// upstream support. The upstreams are queried on each call so an upstream that was upgraded
+// or replaced is reflected right away, an upstream that can't be reached is represented by
+// the capabilities it reported last, or by the legacy methods if it never answered so the
+// consensus client is never offered a method an upstream may not serve.
+func (e *EngineService) ExchangeCapabilities(r *http.Request, args *ExchangeCapabilitiesArgs, reply *[]string) error {
+	ctx := r.Context()
+	zlogger := logging.Logger(ctx, zlog)
+
+	supported := engineMethods()
+	for _, response := range e.fanOut(ctx, "engine_exchangeCapabilities", supported) {
+		methods, err := decodeCapabilities(response)
+		if err != nil {
+			zlogger.Warn("unable to refresh upstream capabilities", zap.Stringer("upstream", response.upstream), zap.Error(err))
+			continue
+		}
+
+		e.capabilities.Set(response.upstream, methods)
+	}
+
+	for _, node := range e.upstreams {
+		methods, found := e.capabilities.Get(node)
+		if !found {
+			zlogger.Warn("upstream capabilities unknown, assuming legacy methods only", zap.Stringer("upstream", node))
+			methods = legacyEngineMethods
+		}
+
+		supported = intersect(supported, methods)
+	}
+
+	zlogger.Debug("exchange capabilities", zap.Strings("consensus", args.Capabilities), zap.Strings("supported", supported))
+
+	*reply = supported
+	return nil
+}
+
+func decodeCapabilities(response *upstreamResponse) ([]string, error) {
+	if response.err != nil {
+		var errResponse *ethrpc.ErrResponse
+		if errors.As(response.err, &errResponse) && errResponse.Code == -32601 {
+			return legacyEngineMethods, nil
+		}
+
+		return nil, response.err
+	}
+
+	var methods []string
+	if err := json.Unmarshal(response.content, &methods); err != nil {
+		return nil, err
+	}
+
+	return methods, nil
+}
+
+var httpRequestType = reflect.TypeOf((*http.Request)(nil))
+
+// engineMethods returns the sorted Engine API methods registered by EngineService, derived
+// from its exported methods the same way the JSON-RPC server maps them, as mandated by the
+// specification `engine_exchangeCapabilities` itself is excluded.
+func engineMethods() []string {
+	serviceType := reflect.TypeOf(&EngineService{})
+
+	var methods []string
+	for i := 0; i < serviceType.NumMethod(); i++ {
+		method := serviceType.Method(i)
+		if method.Name == "ExchangeCapabilities" || method.Type.NumIn() != 4 || method.Type.In(1) != httpRequestType {
+			continue
+		}
+
+		methods = append(methods, "engine_"+strings.ToLower(method.Name[:1])+method.Name[1:])
+	}
+
+	sort.Strings(methods)
+	return methods
+}
+
+// intersect returns the elements of `left` that are also in `right`, preserving their order.
+func intersect(left, right []string) (out []string) {
+	in := make(map[string]bool, len(right))
+	for _, element := range right {
+		in[element] = true
+	}
+
+	out = []string{}
+	for _, element := range left {
+		if in[element] {
+			out = append(out, element)
+		}
+	}
+
+	return out
+}
+
+// capabilitiesCache keeps the last Engine API methods reported by each upstream.
+type capabilitiesCache struct {
+	lock       sync.Mutex
+	byUpstream map[*upstream.Client][]string
+}
+
+func newCapabilitiesCache() *capabilitiesCache {
+	return &capabilitiesCache{
+		byUpstream: make(map[*upstream.Client][]string),
+	}
+}
+
+func (c *capabilitiesCache) Set(node *upstream.Client, methods []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.byUpstream[node] = methods
+}
+
+func (c *capabilitiesCache) Get(node *upstream.Client) (methods []string, found bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	methods, found = c.byUpstream[node]
+	return
+}
+
+func (a *ExchangeCapabilitiesArgs) Validate(requestInfo *rpc.RequestInfo) error {
+	return nil
+}
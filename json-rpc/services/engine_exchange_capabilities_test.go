@@ -0,0 +1,126 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineMethods(t *testing.T) {
+	assert.Equal(t, []string{
+		"engine_exchangeTransitionConfigurationV1",
+		"engine_forkchoiceUpdatedV1",
+		"engine_forkchoiceUpdatedV2",
+		"engine_forkchoiceUpdatedV3",
+		"engine_getPayloadBodiesByHashV1",
+		"engine_getPayloadBodiesByRangeV1",
+		"engine_getPayloadV1",
+		"engine_getPayloadV2",
+		"engine_getPayloadV3",
+		"engine_newPayloadV1",
+		"engine_newPayloadV2",
+		"engine_newPayloadV3",
+	}, engineMethods())
+}
+
+func TestIntersect(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     []string
+		right    []string
+		expected []string
+	}{
+		{"both empty", nil, nil, []string{}},
+		{"right empty", []string{"a", "b"}, nil, []string{}},
+		{"disjoint", []string{"a", "b"}, []string{"c"}, []string{}},
+		{"keeps left order", []string{"c", "a", "b"}, []string{"b", "c"}, []string{"c", "b"}},
+		{"identical", []string{"a", "b"}, []string{"a", "b"}, []string{"a", "b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, intersect(test.left, test.right))
+		})
+	}
+}
+
+func TestEngineService_ExchangeCapabilities(t *testing.T) {
+	newUpstream := func(response string) *upstream.Client {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(response))
+		}))
+		t.Cleanup(server.Close)
+
+		return upstream.NewClient(server.URL, nil)
+	}
+
+	current := newUpstream(`{"jsonrpc":"2.0","id":1,"result":["engine_newPayloadV1","engine_newPayloadV2","engine_forkchoiceUpdatedV1","engine_forkchoiceUpdatedV2","engine_getPayloadV1","engine_getPayloadV2","engine_unknownV9"]}`)
+	legacy := newUpstream(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method engine_exchangeCapabilities does not exist/is not available"}}`)
+	failing := newUpstream(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"internal error"}}`)
+
+	offlineServer := httptest.NewServer(http.NotFoundHandler())
+	offline := upstream.NewClient(offlineServer.URL, nil)
+	offlineServer.Close()
+
+	exchange := func(service *EngineService) (reply []string) {
+		require.NoError(t, service.ExchangeCapabilities(httptest.NewRequest("POST", "/", nil), &ExchangeCapabilitiesArgs{}, &reply))
+		return
+	}
+
+	currentMethods := []string{
+		"engine_forkchoiceUpdatedV1",
+		"engine_forkchoiceUpdatedV2",
+		"engine_getPayloadV1",
+		"engine_getPayloadV2",
+		"engine_newPayloadV1",
+		"engine_newPayloadV2",
+	}
+	legacyMethods := []string{
+		"engine_forkchoiceUpdatedV1",
+		"engine_getPayloadV1",
+		"engine_newPayloadV1",
+	}
+
+	tests := []struct {
+		name      string
+		upstreams []*upstream.Client
+		expected  []string
+	}{
+		{"current only", []*upstream.Client{current}, currentMethods},
+		{"legacy upstream", []*upstream.Client{current, legacy}, legacyMethods},
+		{"failing upstream never answered", []*upstream.Client{current, failing}, legacyMethods},
+		{"offline upstream never answered", []*upstream.Client{current, offline}, legacyMethods},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &EngineService{upstreams: test.upstreams, capabilities: newCapabilitiesCache()}
+			assert.Equal(t, test.expected, exchange(service))
+		})
+	}
+
+	t.Run("unreachable upstream keeps last known capabilities", func(t *testing.T) {
+		service := &EngineService{upstreams: []*upstream.Client{current, offline}, capabilities: newCapabilitiesCache()}
+		service.capabilities.Set(offline, currentMethods)
+
+		assert.Equal(t, currentMethods, exchange(service))
+	})
+}
@@ -15,17 +15,56 @@
 package services
 
 import (
-	"go.uber.org/zap"
+	"encoding/json"
+	"errors"
 	"net/http"
 
+	ethrpc "github.com/streamingfast/eth-go/rpc"
 	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
 )
 
+// ExchangeTransitionConfigurationV1 answers with the transition configuration of the network
+// the proxy is configured for. The consensus client and every upstream are compared against
+// it, a divergence is logged and counted but never fails the call as mandated by the spec.
+// The upstreams are sent the proxy's configuration, not the consensus client's one, so an
+// upstream rejecting it with an error is a divergence too.
 func (e *EngineService) ExchangeTransitionConfigurationV1(r *http.Request, args *TransitionConfigurationV1Args, reply *TransitionConfigurationV1Args) error {
 	ctx := r.Context()
 	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("Exchange Transaction Configuration v1 :", zap.Reflect("args", args))
-	zlogger.Info("Exchange Transaction Configuration v1 :", zap.Reflect("reply", reply))
 
+	expected := newTransitionConfigurationV1(e.chainConfig)
+	if !expected.Equal(args) {
+		TransitionConfigurationMismatchCount.Inc("consensus")
+		zlogger.Warn("consensus client transition configuration differs from proxy configuration", zap.Object("consensus", args), zap.Object("proxy", expected))
+	}
+
+	for _, response := range e.fanOut(ctx, "engine_exchangeTransitionConfigurationV1", expected) {
+		if response.err != nil {
+			// Execution clients answer with an error when the terminal total difficulty differs
+			var errResponse *ethrpc.ErrResponse
+			if errors.As(response.err, &errResponse) {
+				TransitionConfigurationMismatchCount.Inc("upstream")
+				zlogger.Warn("upstream rejected proxy transition configuration", zap.Stringer("upstream", response.upstream), zap.Object("proxy", expected), zap.Error(response.err))
+				continue
+			}
+
+			zlogger.Warn("upstream call failed", zap.String("method", "engine_exchangeTransitionConfigurationV1"), zap.Stringer("upstream", response.upstream), zap.Error(response.err))
+			continue
+		}
+
+		upstreamConfig := &TransitionConfigurationV1Args{}
+		if err := json.Unmarshal(response.content, upstreamConfig); err != nil {
+			zlogger.Warn("upstream returned an invalid transition configuration", zap.Stringer("upstream", response.upstream), zap.ByteString("content", response.content), zap.Error(err))
+			continue
+		}
+
+		if !expected.Equal(upstreamConfig) {
+			TransitionConfigurationMismatchCount.Inc("upstream")
+			zlogger.Warn("upstream transition configuration differs from proxy configuration", zap.Stringer("upstream", response.upstream), zap.Object("upstream_config", upstreamConfig), zap.Object("proxy", expected))
+		}
+	}
+
+	*reply = *expected
 	return nil
 }
@@ -0,0 +1,106 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/config"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineService_ExchangeTransitionConfigurationV1(t *testing.T) {
+	chainConfig, err := config.NetworkNameToChainConfig("mainnet")
+	require.NoError(t, err)
+
+	proxyConfig := newTransitionConfigurationV1(chainConfig)
+	otherConfig := &TransitionConfigurationV1Args{TerminalTotalDifficulty: BigInt(*big.NewInt(1)), TerminalBlockHash: eth.MustNewHash("0x01"), TerminalBlockNumber: 1}
+
+	var lock sync.Mutex
+	var receivedConfigs []string
+	newNode := func(answer func(params json.RawMessage) string) *upstream.Client {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			request := struct {
+				ID     json.RawMessage   `json:"id"`
+				Params []json.RawMessage `json:"params"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+			lock.Lock()
+			receivedConfigs = append(receivedConfigs, string(request.Params[0]))
+			lock.Unlock()
+
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,%s}`, request.ID, answer(request.Params[0]))
+		}))
+		t.Cleanup(server.Close)
+
+		return upstream.NewClient(server.URL, nil)
+	}
+
+	echoing := newNode(func(params json.RawMessage) string { return `"result":` + string(params) })
+	diverging := newNode(func(json.RawMessage) string {
+		return `"result":{"terminalTotalDifficulty":"0x1","terminalBlockHash":"0x0000000000000000000000000000000000000000000000000000000000000001","terminalBlockNumber":"0x1"}`
+	})
+	rejecting := newNode(func(json.RawMessage) string {
+		return `"error":{"code":-32000,"message":"invalid ttd: execution 1 consensus 58750000000000000000000"}`
+	})
+
+	tests := []struct {
+		name              string
+		upstreams         []*upstream.Client
+		args              *TransitionConfigurationV1Args
+		expectedConsensus float64
+		expectedUpstream  float64
+	}{
+		{"all agree", []*upstream.Client{echoing}, proxyConfig, 0, 0},
+		{"consensus mismatch", []*upstream.Client{echoing}, otherConfig, 1, 0},
+		{"upstream mismatch", []*upstream.Client{echoing, diverging}, proxyConfig, 0, 1},
+		{"upstream rejects proxy configuration", []*upstream.Client{echoing, rejecting}, proxyConfig, 0, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receivedConfigs = nil
+			consensusBefore := testutil.ToFloat64(TransitionConfigurationMismatchCount.Native().WithLabelValues("consensus"))
+			upstreamBefore := testutil.ToFloat64(TransitionConfigurationMismatchCount.Native().WithLabelValues("upstream"))
+
+			service := NewEngineService(chainConfig, test.upstreams, test.upstreams[0], QuorumPolicyAnyValid, nil, nil, "", nil)
+
+			reply := &TransitionConfigurationV1Args{}
+			require.NoError(t, service.ExchangeTransitionConfigurationV1(httptest.NewRequest("POST", "/", nil), test.args, reply))
+
+			assert.True(t, proxyConfig.Equal(reply), "reply is always the proxy configuration")
+			assert.Equal(t, test.expectedConsensus, testutil.ToFloat64(TransitionConfigurationMismatchCount.Native().WithLabelValues("consensus"))-consensusBefore)
+			assert.Equal(t, test.expectedUpstream, testutil.ToFloat64(TransitionConfigurationMismatchCount.Native().WithLabelValues("upstream"))-upstreamBefore)
+
+			require.Len(t, receivedConfigs, len(test.upstreams))
+			for _, received := range receivedConfigs {
+				sent := &TransitionConfigurationV1Args{}
+				require.NoError(t, json.Unmarshal([]byte(received), sent))
+				assert.True(t, proxyConfig.Equal(sent), "upstreams are sent the proxy configuration, got %s", received)
+			}
+		})
+	}
+}
@@ -17,37 +17,24 @@ package services
 import (
 	"github.com/gorilla/rpc/v2"
 	"github.com/streamingfast/eth-go"
-	"go.uber.org/zap"
-	"net/http"
-
-	"github.com/streamingfast/logging"
 )
 
 type ExecutionPayloadV2Args struct {
-	ParentHash    eth.Hash              `json:"parentHash"`
-	FeeRecipient  eth.Address           `json:"feeRecipient"`
-	StateRoot     eth.Hash              `json:"stateRoot"`
-	ReceiptsRoot  eth.Hash              `json:"receiptsRoot"`
-	LogsBloom     eth.Hex               `json:"logsBloom"`
-	PrevRandao    eth.Hash              `json:"prevRandao"`
-	BlockNumber   eth.Uint64            `json:"blockNumber"`
-	GasLimit      eth.Uint64            `json:"gasLimit"`
-	GasUsed       eth.Uint64            `json:"gasUsed"`
-	Timestamp     eth.Uint64            `json:"timestamp"`
-	ExtraData     eth.Hash              `json:"extraData"`
-	BaseFeePerGas BigInt                `json:"baseFeePerGas"`
-	BlockHash     eth.Hash              `json:"blockHash"`
-	Transactions  []eth.TransactionType `json:"transactions"`
-	Withdrawals   []WithdrawalV1Args    `json:"withdrawals"`
-}
-
-func (e *EngineService) ExecutePayLoadV2(r *http.Request, args *ExecutionPayloadV2Args, reply *eth.Hex) error {
-	ctx := r.Context()
-	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("engine execute payload v2 args:", zap.Reflect("args", args))
-	zlogger.Info("engine execute payload v2 reply:", zap.Reflect("reply", reply))
-
-	return nil
+	ParentHash    eth.Hash           `json:"parentHash"`
+	FeeRecipient  eth.Address        `json:"feeRecipient"`
+	StateRoot     eth.Hash           `json:"stateRoot"`
+	ReceiptsRoot  eth.Hash           `json:"receiptsRoot"`
+	LogsBloom     eth.Hex            `json:"logsBloom"`
+	PrevRandao    eth.Hash           `json:"prevRandao"`
+	BlockNumber   eth.Uint64         `json:"blockNumber"`
+	GasLimit      eth.Uint64         `json:"gasLimit"`
+	GasUsed       eth.Uint64         `json:"gasUsed"`
+	Timestamp     eth.Uint64         `json:"timestamp"`
+	ExtraData     eth.Hex            `json:"extraData"`
+	BaseFeePerGas BigInt             `json:"baseFeePerGas"`
+	BlockHash     eth.Hash           `json:"blockHash"`
+	Transactions  []eth.Hex          `json:"transactions"`
+	Withdrawals   []WithdrawalV1Args `json:"withdrawals"`
 }
 
 func (e *ExecutionPayloadV2Args) Validate(requestInfo *rpc.RequestInfo) error {
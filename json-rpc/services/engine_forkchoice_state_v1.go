@@ -17,27 +17,14 @@ package services
 import (
 	"github.com/gorilla/rpc/v2"
 	"github.com/streamingfast/eth-go"
-	"go.uber.org/zap"
-	"net/http"
-
-	"github.com/streamingfast/logging"
 )
 
 type ForkchoiceStateV1Args struct {
 	HeadBlockHash      eth.Hash `json:"headBlockHash"`
-	SafeBlockHash      eth.Hash `json:"SafeBlockHash"`
+	SafeBlockHash      eth.Hash `json:"safeBlockHash"`
 	FinalizedBlockHash eth.Hash `json:"finalizedBlockHash"`
 }
 
-func (e *EngineService) ForkchoiceStateV1(r *http.Request, args *ForkchoiceStateV1Args, reply *eth.Hex) error {
-	ctx := r.Context()
-	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("engine forkchoice state v1 args:", zap.Reflect("args", args))
-	zlogger.Info("engine forkchoice state v1 reply:", zap.Reflect("reply", reply))
-
-	return nil
-}
-
 func (e *ForkchoiceStateV1Args) Validate(requestInfo *rpc.RequestInfo) error {
 	return nil
 }
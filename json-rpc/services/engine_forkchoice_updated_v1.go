@@ -15,16 +15,24 @@
 package services
 
 import (
-	"github.com/streamingfast/eth-go"
-	"go.uber.org/zap"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/streamingfast/eth-go"
+	ethrpc "github.com/streamingfast/eth-go/rpc"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
 	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
 )
 
 type ForkchoiceUpdatedV1Args struct {
-	forkchoiceState   ForkchoiceStateV1Args
-	payloadAttributes PayloadAttributesV1Args
+	ForkchoiceState   ForkchoiceStateV1Args    `json:"forkchoiceState"`
+	PayloadAttributes *PayloadAttributesV1Args `json:"payloadAttributes"`
 }
 
 type ForkChoiceUpdatedV1Status string
@@ -36,15 +44,144 @@ const (
 )
 
 type ForkchoiceUpdatedV1Reply struct {
-	forkchoiceStatus ForkChoiceUpdatedV1Status
-	payloadId        eth.Hash
+	PayloadStatus PayloadStatusV1Args `json:"payloadStatus"`
+	PayloadID     *eth.Hex            `json:"payloadId"`
 }
 
 func (e *EngineService) ForkchoiceUpdatedV1(r *http.Request, args *ForkchoiceUpdatedV1Args, reply *ForkchoiceUpdatedV1Reply) error {
+	var attributes interface{}
+	var timestamp eth.Uint64
+	if args.PayloadAttributes != nil {
+		if err := e.checkFork(args.PayloadAttributes.Timestamp, engineForkParis, engineForkParis); err != nil {
+			return err
+		}
+
+		attributes = args.PayloadAttributes
+		timestamp = args.PayloadAttributes.Timestamp
+	}
+
+	return e.forkchoiceUpdated(r, "engine_forkchoiceUpdatedV1", args.ForkchoiceState, attributes, timestamp, reply)
+}
+
+// forkchoiceUpdated is shared by all versions of `engine_forkchoiceUpdated`, `attributes` is
+// the version specific payload attributes and must be an untyped nil when none were provided,
+// `timestamp` is the timestamp found in the payload attributes.
+func (e *EngineService) forkchoiceUpdated(r *http.Request, method string, state ForkchoiceStateV1Args, attributes interface{}, timestamp eth.Uint64, reply *ForkchoiceUpdatedV1Reply) error {
 	ctx := r.Context()
 	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("Forkchoice Updated v1 args:", zap.Reflect("args", args))
-	zlogger.Info("Forkchoice Updated v1 reply:", zap.Reflect("reply", reply))
+	zlogger.Debug("forkchoice updated",
+		zap.String("method", method),
+		zap.Stringer("head_block_hash", state.HeadBlockHash),
+		zap.Stringer("safe_block_hash", state.SafeBlockHash),
+		zap.Stringer("finalized_block_hash", state.FinalizedBlockHash),
+		zap.Bool("with_payload_attributes", attributes != nil),
+	)
+
+	if len(e.upstreams) == 0 {
+		return &json2.Error{Code: json2.E_SERVER, Message: "no upstream execution node configured"}
+	}
+
+	// Payload attributes are not recorded, a replayed or caught up node must only follow the chain
+	seq := e.record(method, state, nil)
+
+	// Payload attributes are sent only to the builder, the other upstreams only follow the chain
+	responses := e.fanOutWith(ctx, method, func(node *upstream.Client) []interface{} {
+		if node == e.builder {
+			return []interface{}{state, attributes}
+		}
+
+		return []interface{}{state, nil}
+	})
+
+	var votes payloadStatusVotes
+	var builderErr error
+	answered := 0
+	for _, response := range responses {
+		vote := &payloadStatusVote{upstream: response.upstream, content: response.content, err: response.err}
+		votes = append(votes, vote)
+
+		if response.err != nil {
+			zlogger.Warn("upstream call failed", zap.String("method", method), zap.Stringer("upstream", response.upstream), zap.Error(response.err))
+			e.health.Record(response.upstream, nil, response.err)
+
+			var errResponse *ethrpc.ErrResponse
+			if response.upstream == e.builder && errors.As(response.err, &errResponse) {
+				builderErr = response.err
+			}
+			continue
+		}
+
+		upstreamReply := &ForkchoiceUpdatedV1Reply{}
+		if err := json.Unmarshal(response.content, upstreamReply); err != nil {
+			zlogger.Warn("upstream returned an invalid forkchoice updated reply", zap.Stringer("upstream", response.upstream), zap.ByteString("content", response.content), zap.Error(err))
+			e.health.Record(response.upstream, nil, err)
+			vote.err = err
+			continue
+		}
+
+		e.health.Record(response.upstream, &upstreamReply.PayloadStatus, nil)
+
+		if response.upstream == e.builder && attributes != nil {
+			reply.PayloadID = upstreamReply.PayloadID
+		}
+
+		vote.status = &upstreamReply.PayloadStatus
+		answered++
+	}
+
+	// The builder rejecting the forkchoice state (-38002) or the payload attributes (-38003)
+	// must reach the consensus client as is, as required by the specification
+	if builderErr != nil {
+		return toJSONRPCError(builderErr)
+	}
+
+	if answered == 0 {
+		return &json2.Error{Code: json2.E_SERVER, Message: fmt.Sprintf("all %d upstream execution nodes failed", len(e.upstreams))}
+	}
+
+	if attributes != nil && reply.PayloadID == nil {
+		zlogger.Warn("payload attributes provided but builder did not return a payload id", zap.Stringer("builder", e.builder))
+	}
+
+	e.backlog.Track(seq, votes)
+	e.detectDivergence(ctx, method, state.HeadBlockHash, 0, votes, []interface{}{state, attributes})
+
+	reply.PayloadStatus = *e.mergeVotes(ctx, method, votes)
+	reply.PayloadStatus.Status = toForkchoiceStatus(reply.PayloadStatus.Status)
+	e.journalCall(ctx, method, reply.PayloadStatus.Status, state, nil)
+
+	// A payload is being built only if the forkchoice state was accepted as VALID
+	if reply.PayloadStatus.Status != EnginePayloadStatusValid {
+		reply.PayloadID = nil
+	} else {
+		if reply.PayloadID != nil {
+			e.payloads.Add(*reply.PayloadID, e.builder, timestamp, time.Now())
+		}
+
+		e.forkchoice.Set(method, state)
+
+		if e.subscriptions != nil {
+			e.subscriptions.HeadUpdated(state.HeadBlockHash)
+		}
+	}
+
+	zlogger.Debug("forkchoice updated completed", zap.String("method", method), zap.String("status", string(reply.PayloadStatus.Status)), zap.Stringer("payload_id", reply.PayloadID))
+	return nil
+}
+
+// toForkchoiceStatus restricts a payload status to the values accepted in a forkchoice
+// updated reply, ACCEPTED has no meaning for a forkchoice update and is seen as SYNCING.
+func toForkchoiceStatus(status EnginePayloadStatus) EnginePayloadStatus {
+	switch status {
+	case EnginePayloadStatusValid:
+		return EnginePayloadStatus(ForkchoiceStatusValid)
+	case EnginePayloadStatusInvalid, EnginePayloadStatusInvalidBlockHash:
+		return EnginePayloadStatus(ForkchoiceStatusInvalid)
+	default:
+		return EnginePayloadStatus(ForkchoiceStatusSyncing)
+	}
+}
 
+func (a *ForkchoiceUpdatedV1Args) Validate(requestInfo *rpc.RequestInfo) error {
 	return nil
 }
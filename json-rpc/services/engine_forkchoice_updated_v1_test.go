@@ -0,0 +1,75 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/config"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineService_ForkchoiceUpdatedV1WithAttributes(t *testing.T) {
+	chainConfig, err := config.NetworkNameToChainConfig("battlefield")
+	require.NoError(t, err)
+
+	var lock sync.Mutex
+	receivedAttributes := map[string]string{}
+	newNode := func(name string) *upstream.Client {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			request := struct {
+				ID     json.RawMessage   `json:"id"`
+				Params []json.RawMessage `json:"params"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+			lock.Lock()
+			receivedAttributes[name] = string(request.Params[1])
+			lock.Unlock()
+
+			payloadID := "null"
+			if string(request.Params[1]) != "null" {
+				payloadID = `"0x0000000000000001"`
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"payloadStatus":{"status":"VALID","latestValidHash":"0x01"},"payloadId":%s}}`, request.ID, payloadID)
+		}))
+		t.Cleanup(server.Close)
+
+		return upstream.NewClient(server.URL, nil)
+	}
+
+	builder := newNode("builder")
+	follower := newNode("follower")
+	service := NewEngineService(chainConfig, []*upstream.Client{builder, follower}, builder, QuorumPolicyAnyValid, nil, nil, "", nil)
+
+	reply := &ForkchoiceUpdatedV1Reply{}
+	require.NoError(t, service.ForkchoiceUpdatedV1(httptest.NewRequest("POST", "/", nil), &ForkchoiceUpdatedV1Args{
+		ForkchoiceState:   ForkchoiceStateV1Args{HeadBlockHash: eth.MustNewHash("0x01"), SafeBlockHash: eth.MustNewHash("0x01"), FinalizedBlockHash: eth.MustNewHash("0x01")},
+		PayloadAttributes: &PayloadAttributesV1Args{Timestamp: 12, PrevRandao: eth.MustNewHash("0x02"), SuggestedFeeRecipient: eth.MustNewAddress("0x0000000000000000000000000000000000000003")},
+	}, reply))
+
+	require.NotNil(t, reply.PayloadID)
+	assert.Equal(t, eth.MustNewHex("0x0000000000000001"), *reply.PayloadID)
+	assert.JSONEq(t, `{"timestamp":"0xc","prevRandao":"0x02","suggestedFeeRecipient":"0x0000000000000000000000000000000000000003"}`, receivedAttributes["builder"], "attributes are sent to the builder")
+	assert.Equal(t, "null", receivedAttributes["follower"], "other upstreams only follow the chain")
+}
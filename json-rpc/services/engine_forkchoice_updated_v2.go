@@ -0,0 +1,50 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/streamingfast/eth-go"
+)
+
+type ForkchoiceUpdatedV2Args struct {
+	ForkchoiceState   ForkchoiceStateV1Args    `json:"forkchoiceState"`
+	PayloadAttributes *PayloadAttributesV2Args `json:"payloadAttributes"`
+}
+
+func (e *EngineService) ForkchoiceUpdatedV2(r *http.Request, args *ForkchoiceUpdatedV2Args, reply *ForkchoiceUpdatedV1Reply) error {
+	var attributes interface{}
+	var timestamp eth.Uint64
+	if args.PayloadAttributes != nil {
+		if err := e.checkFork(args.PayloadAttributes.Timestamp, engineForkParis, engineForkShanghai); err != nil {
+			return err
+		}
+
+		if err := e.checkWithdrawals(args.PayloadAttributes.Timestamp, args.PayloadAttributes.Withdrawals); err != nil {
+			return err
+		}
+
+		attributes = args.PayloadAttributes
+		timestamp = args.PayloadAttributes.Timestamp
+	}
+
+	return e.forkchoiceUpdated(r, "engine_forkchoiceUpdatedV2", args.ForkchoiceState, attributes, timestamp, reply)
+}
+
+func (a *ForkchoiceUpdatedV2Args) Validate(requestInfo *rpc.RequestInfo) error {
+	return nil
+}
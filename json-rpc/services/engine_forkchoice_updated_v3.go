@@ -0,0 +1,48 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type ForkchoiceUpdatedV3Args struct {
+	ForkchoiceState   ForkchoiceStateV1Args    `json:"forkchoiceState"`
+	PayloadAttributes *PayloadAttributesV3Args `json:"payloadAttributes"`
+}
+
+func (e *EngineService) ForkchoiceUpdatedV3(r *http.Request, args *ForkchoiceUpdatedV3Args, reply *ForkchoiceUpdatedV1Reply) error {
+	if args.PayloadAttributes == nil {
+		return e.forkchoiceUpdated(r, "engine_forkchoiceUpdatedV3", args.ForkchoiceState, nil, 0, reply)
+	}
+
+	attributes := args.PayloadAttributes
+	if attributes.Withdrawals == nil || attributes.ParentBeaconBlockRoot == nil {
+		return &json2.Error{Code: json2.E_BAD_PARAMS, Message: "withdrawals and parentBeaconBlockRoot are required"}
+	}
+
+	if err := e.checkFork(attributes.Timestamp, engineForkCancun, engineForkCancun); err != nil {
+		return err
+	}
+
+	return e.forkchoiceUpdated(r, "engine_forkchoiceUpdatedV3", args.ForkchoiceState, attributes, attributes.Timestamp, reply)
+}
+
+func (a *ForkchoiceUpdatedV3Args) Validate(requestInfo *rpc.RequestInfo) error {
+	return nil
+}
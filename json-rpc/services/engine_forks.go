@@ -0,0 +1,82 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/streamingfast/eth-go"
+)
+
+// engineFork is the execution layer fork active at a given timestamp, it determines which
+// version of the Engine API methods the consensus client is allowed to call.
+type engineFork uint8
+
+const (
+	engineForkParis engineFork = iota
+	engineForkShanghai
+	engineForkCancun
+)
+
+func (f engineFork) String() string {
+	switch f {
+	case engineForkParis:
+		return "paris"
+	case engineForkShanghai:
+		return "shanghai"
+	case engineForkCancun:
+		return "cancun"
+	default:
+		return fmt.Sprintf("unknown(%d)", f)
+	}
+}
+
+func (e *EngineService) forkAt(timestamp eth.Uint64) engineFork {
+	switch {
+	case e.chainConfig.IsCancunTime(uint64(timestamp)):
+		return engineForkCancun
+	case e.chainConfig.IsShanghaiTime(uint64(timestamp)):
+		return engineForkShanghai
+	default:
+		return engineForkParis
+	}
+}
+
+// checkFork returns the spec's `Unsupported fork` error if the fork active at `timestamp` is
+// not within [`lowest`, `highest`], the forks supported by the method version being called.
+func (e *EngineService) checkFork(timestamp eth.Uint64, lowest, highest engineFork) error {
+	if fork := e.forkAt(timestamp); fork < lowest || fork > highest {
+		return &json2.Error{Code: EngineErrUnsupportedFork, Message: fmt.Sprintf("Unsupported fork %s at timestamp %d", fork, uint64(timestamp))}
+	}
+
+	return nil
+}
+
+// checkWithdrawals returns an invalid params error if the presence of `withdrawals` does not
+// match the fork active at `timestamp`, they are mandatory starting with Shanghai and
+// forbidden before it.
+func (e *EngineService) checkWithdrawals(timestamp eth.Uint64, withdrawals []WithdrawalV1Args) error {
+	shanghai := e.forkAt(timestamp) >= engineForkShanghai
+	if shanghai && withdrawals == nil {
+		return &json2.Error{Code: json2.E_BAD_PARAMS, Message: "withdrawals are required starting with Shanghai"}
+	}
+
+	if !shanghai && withdrawals != nil {
+		return &json2.Error{Code: json2.E_BAD_PARAMS, Message: "withdrawals are not supported before Shanghai"}
+	}
+
+	return nil
+}
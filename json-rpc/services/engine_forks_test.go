@@ -0,0 +1,104 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"testing"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newForksTestService(t *testing.T) *EngineService {
+	chainConfig, err := config.NetworkNameToChainConfig("mainnet")
+	require.NoError(t, err)
+
+	return &EngineService{chainConfig: chainConfig}
+}
+
+func TestEngineService_CheckFork(t *testing.T) {
+	service := newForksTestService(t)
+	shanghai := eth.Uint64(*service.chainConfig.ShanghaiTime)
+	cancun := eth.Uint64(*service.chainConfig.CancunTime)
+
+	tests := []struct {
+		name        string
+		timestamp   eth.Uint64
+		lowest      engineFork
+		highest     engineFork
+		expectedErr bool
+	}{
+		{"V1 just before Shanghai", shanghai - 1, engineForkParis, engineForkParis, false},
+		{"V1 at Shanghai", shanghai, engineForkParis, engineForkParis, true},
+		{"V2 before Shanghai", shanghai - 1, engineForkParis, engineForkShanghai, false},
+		{"V2 at Shanghai", shanghai, engineForkParis, engineForkShanghai, false},
+		{"V2 just before Cancun", cancun - 1, engineForkParis, engineForkShanghai, false},
+		{"V2 at Cancun", cancun, engineForkParis, engineForkShanghai, true},
+		{"V3 before Shanghai", shanghai - 1, engineForkCancun, engineForkCancun, true},
+		{"V3 just before Cancun", cancun - 1, engineForkCancun, engineForkCancun, true},
+		{"V3 at Cancun", cancun, engineForkCancun, engineForkCancun, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := service.checkFork(test.timestamp, test.lowest, test.highest)
+			if !test.expectedErr {
+				assert.NoError(t, err)
+				return
+			}
+
+			var rpcErr *json2.Error
+			require.ErrorAs(t, err, &rpcErr)
+			assert.Equal(t, EngineErrUnsupportedFork, rpcErr.Code)
+		})
+	}
+}
+
+func TestEngineService_CheckWithdrawals(t *testing.T) {
+	service := newForksTestService(t)
+	shanghai := eth.Uint64(*service.chainConfig.ShanghaiTime)
+	cancun := eth.Uint64(*service.chainConfig.CancunTime)
+
+	tests := []struct {
+		name        string
+		timestamp   eth.Uint64
+		withdrawals []WithdrawalV1Args
+		expectedErr bool
+	}{
+		{"without withdrawals just before Shanghai", shanghai - 1, nil, false},
+		{"with withdrawals just before Shanghai", shanghai - 1, []WithdrawalV1Args{}, true},
+		{"without withdrawals at Shanghai", shanghai, nil, true},
+		{"with withdrawals at Shanghai", shanghai, []WithdrawalV1Args{}, false},
+		{"with withdrawals at Cancun", cancun, []WithdrawalV1Args{{Index: 1}}, false},
+		{"without withdrawals at Cancun", cancun, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := service.checkWithdrawals(test.timestamp, test.withdrawals)
+			if !test.expectedErr {
+				assert.NoError(t, err)
+				return
+			}
+
+			var rpcErr *json2.Error
+			require.ErrorAs(t, err, &rpcErr)
+			assert.Equal(t, json2.E_BAD_PARAMS, rpcErr.Code)
+		})
+	}
+}
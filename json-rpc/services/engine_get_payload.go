@@ -0,0 +1,90 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
+)
+
+type GetPayloadArgs struct {
+	PayloadID eth.Hex `json:"payloadId"`
+}
+
+type GetPayloadV2Reply struct {
+	ExecutionPayload ExecutionPayloadV2Args `json:"executionPayload"`
+	BlockValue       BigInt                 `json:"blockValue"`
+}
+
+type GetPayloadV3Reply struct {
+	ExecutionPayload      ExecutionPayloadV3Args `json:"executionPayload"`
+	BlockValue            BigInt                 `json:"blockValue"`
+	BlobsBundle           BlobsBundleV1Args      `json:"blobsBundle"`
+	ShouldOverrideBuilder bool                   `json:"shouldOverrideBuilder"`
+}
+
+func (e *EngineService) GetPayloadV1(r *http.Request, args *GetPayloadArgs, reply *ExecutionPayloadV1Args) error {
+	return e.getPayload(r, "engine_getPayloadV1", args, engineForkParis, engineForkParis, reply)
+}
+
+func (e *EngineService) GetPayloadV2(r *http.Request, args *GetPayloadArgs, reply *GetPayloadV2Reply) error {
+	return e.getPayload(r, "engine_getPayloadV2", args, engineForkParis, engineForkShanghai, reply)
+}
+
+func (e *EngineService) GetPayloadV3(r *http.Request, args *GetPayloadArgs, reply *GetPayloadV3Reply) error {
+	return e.getPayload(r, "engine_getPayloadV3", args, engineForkCancun, engineForkCancun, reply)
+}
+
+// getPayload routes the call to the upstream that returned the payload id in a previous
+// forkchoice updated call, unknown or expired payload ids are rejected right away as well
+// as payloads built for a fork outside of [`lowest`, `highest`].
+func (e *EngineService) getPayload(r *http.Request, method string, args *GetPayloadArgs, lowest, highest engineFork, reply interface{}) error {
+	ctx := r.Context()
+	zlogger := logging.Logger(ctx, zlog)
+
+	entry := e.payloads.Get(args.PayloadID, time.Now())
+	if entry == nil {
+		zlogger.Info("unknown payload id", zap.String("method", method), zap.Stringer("payload_id", args.PayloadID))
+		return ErrUnknownPayload
+	}
+
+	if err := e.checkFork(entry.timestamp, lowest, highest); err != nil {
+		return err
+	}
+
+	node := entry.upstream
+
+	zlogger.Debug("get payload", zap.String("method", method), zap.Stringer("payload_id", args.PayloadID), zap.Stringer("upstream", node))
+
+	ctx, cancel := context.WithTimeout(ctx, upstreamCallTimeout)
+	defer cancel()
+
+	if err := node.Call(ctx, method, []interface{}{args.PayloadID}, reply); err != nil {
+		zlogger.Warn("upstream get payload failed", zap.String("method", method), zap.Stringer("upstream", node), zap.Error(err))
+		return toJSONRPCError(err)
+	}
+
+	return nil
+}
+
+func (a *GetPayloadArgs) Validate(requestInfo *rpc.RequestInfo) error {
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/config"
+	"github.com/streamingfast/geth-proxy/json-rpc/enginetest"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// payloadBodiesChain imports `count` blocks on top of the genesis of `engine` and makes the last
+// one its head, each block holds a single transaction `<prefix><number>` so bodies served by
+// different engines can be told apart.
+func payloadBodiesChain(t *testing.T, engine *enginetest.Engine, client *upstream.Client, prefix string, count int) (hashes []eth.Hash) {
+	ctx := context.Background()
+
+	parent := engine.Genesis().Hash
+	for number := 1; number <= count; number++ {
+		payload := &services.ExecutionPayloadV1Args{
+			ParentHash:   parent,
+			BlockHash:    eth.MustNewHash(fmt.Sprintf("0x%s%02x", prefix, number)),
+			BlockNumber:  eth.Uint64(number),
+			Transactions: []eth.Hex{eth.MustNewHex(fmt.Sprintf("0x%s%02x", prefix, number))},
+		}
+
+		status := &services.PayloadStatusV1Args{}
+		require.NoError(t, client.Call(ctx, "engine_newPayloadV1", []interface{}{payload}, status))
+		require.Equal(t, services.EnginePayloadStatusValid, status.Status)
+
+		parent = payload.BlockHash
+		hashes = append(hashes, parent)
+	}
+
+	state := &services.ForkchoiceStateV1Args{HeadBlockHash: parent, SafeBlockHash: parent, FinalizedBlockHash: parent}
+	require.NoError(t, client.Call(ctx, "engine_forkchoiceUpdatedV1", []interface{}{state, nil}, &services.ForkchoiceUpdatedV1Reply{}))
+
+	return hashes
+}
+
+func bodiesTransactions(bodies []*services.ExecutionPayloadBodyV1Args) (out []string) {
+	for _, body := range bodies {
+		if body == nil {
+			out = append(out, "null")
+			continue
+		}
+
+		for _, transaction := range body.Transactions {
+			out = append(out, transaction.String())
+		}
+	}
+
+	return out
+}
+
+func lastCallParams(t *testing.T, engine *enginetest.Engine, method string) string {
+	var params []json.RawMessage
+	for _, call := range engine.Calls() {
+		if call.Method == method {
+			params = call.Params
+		}
+	}
+	require.NotNil(t, params, "no %s call received", method)
+
+	content, err := json.Marshal(params)
+	require.NoError(t, err)
+
+	return string(content)
+}
+
+func TestEngineService_GetPayloadBodies(t *testing.T) {
+	chainConfig, err := config.NetworkNameToChainConfig("battlefield")
+	require.NoError(t, err)
+
+	var engines []*enginetest.Engine
+	var upstreams []*upstream.Client
+	for i := 0; i < 2; i++ {
+		engine := enginetest.NewEngine(1337)
+		server := httptest.NewServer(engine)
+		defer server.Close()
+
+		engines = append(engines, engine)
+		upstreams = append(upstreams, upstream.NewClient(server.URL, nil))
+	}
+
+	// The first upstream lags behind with 2 blocks, the second one has 4 different blocks
+	hashesA := payloadBodiesChain(t, engines[0], upstreams[0], "aa", 2)
+	hashesB := payloadBodiesChain(t, engines[1], upstreams[1], "bb", 4)
+	unknown := eth.MustNewHash("0xff")
+
+	service := services.NewEngineService(chainConfig, upstreams, upstreams[0], services.QuorumPolicyAnyValid, nil, nil, "", nil)
+	request := httptest.NewRequest("POST", "/", nil)
+
+	t.Run("by hash fills null bodies from the next upstream", func(t *testing.T) {
+		var bodies []*services.ExecutionPayloadBodyV1Args
+		require.NoError(t, service.GetPayloadBodiesByHashV1(request, &services.GetPayloadBodiesByHashV1Args{
+			BlockHashes: []eth.Hash{hashesB[0], hashesA[1], unknown, hashesB[3]},
+		}, &bodies))
+
+		assert.Equal(t, []string{"bb01", "aa02", "null", "bb04"}, bodiesTransactions(bodies))
+		assert.Equal(t, `[["0xbb01","0xff","0xbb04"]]`, lastCallParams(t, engines[1], "engine_getPayloadBodiesByHashV1"), "only missing bodies are re-requested")
+	})
+
+	t.Run("by hash prefers the first upstream", func(t *testing.T) {
+		calls := engines[1].CallCount("engine_getPayloadBodiesByHashV1")
+
+		var bodies []*services.ExecutionPayloadBodyV1Args
+		require.NoError(t, service.GetPayloadBodiesByHashV1(request, &services.GetPayloadBodiesByHashV1Args{BlockHashes: hashesA}, &bodies))
+
+		assert.Equal(t, []string{"aa01", "aa02"}, bodiesTransactions(bodies))
+		assert.Equal(t, calls, engines[1].CallCount("engine_getPayloadBodiesByHashV1"), "all bodies found, second upstream not queried")
+	})
+
+	t.Run("by hash unknown everywhere", func(t *testing.T) {
+		var bodies []*services.ExecutionPayloadBodyV1Args
+		require.NoError(t, service.GetPayloadBodiesByHashV1(request, &services.GetPayloadBodiesByHashV1Args{BlockHashes: []eth.Hash{unknown}}, &bodies))
+
+		assert.Equal(t, []*services.ExecutionPayloadBodyV1Args{nil}, bodies)
+	})
+
+	t.Run("by range completes from the next upstream", func(t *testing.T) {
+		var bodies []*services.ExecutionPayloadBodyV1Args
+		require.NoError(t, service.GetPayloadBodiesByRangeV1(request, &services.GetPayloadBodiesByRangeV1Args{Start: 1, Count: 6}, &bodies))
+
+		assert.Equal(t, []string{"aa01", "aa02", "bb03", "bb04"}, bodiesTransactions(bodies), "bodies past the latest known block are trimmed")
+		assert.Equal(t, `["0x3","0x4"]`, lastCallParams(t, engines[1], "engine_getPayloadBodiesByRangeV1"), "only the missing range is re-requested")
+	})
+
+	t.Run("by range past every head", func(t *testing.T) {
+		var bodies []*services.ExecutionPayloadBodyV1Args
+		require.NoError(t, service.GetPayloadBodiesByRangeV1(request, &services.GetPayloadBodiesByRangeV1Args{Start: 10, Count: 2}, &bodies))
+
+		assert.Empty(t, bodies)
+	})
+
+	t.Run("all upstreams failed", func(t *testing.T) {
+		engines[0].SetOffline(true)
+		engines[1].SetOffline(true)
+		defer engines[0].SetOffline(false)
+		defer engines[1].SetOffline(false)
+
+		var bodies []*services.ExecutionPayloadBodyV1Args
+		assert.Error(t, service.GetPayloadBodiesByRangeV1(request, &services.GetPayloadBodiesByRangeV1Args{Start: 1, Count: 2}, &bodies))
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
+)
+
+// maxPayloadBodiesRequest is the maximum number of bodies a consensus client may request
+// in a single call as defined by the specification.
+const maxPayloadBodiesRequest = 1024
+
+type ExecutionPayloadBodyV1Args struct {
+	Transactions []eth.Hex          `json:"transactions"`
+	Withdrawals  []WithdrawalV1Args `json:"withdrawals"`
+}
+
+type GetPayloadBodiesByHashV1Args struct {
+	BlockHashes []eth.Hash `json:"blockHashes"`
+}
+
+type GetPayloadBodiesByRangeV1Args struct {
+	Start eth.Uint64 `json:"start"`
+	Count eth.Uint64 `json:"count"`
+}
+
+func (e *EngineService) GetPayloadBodiesByHashV1(r *http.Request, args *GetPayloadBodiesByHashV1Args, reply *[]*ExecutionPayloadBodyV1Args) error {
+	if len(args.BlockHashes) > maxPayloadBodiesRequest {
+		return &json2.Error{Code: EngineErrTooLargeRequest, Message: fmt.Sprintf("Too large request, %d block hashes requested, maximum is %d", len(args.BlockHashes), maxPayloadBodiesRequest)}
+	}
+
+	bodies, err := e.getPayloadBodies(r, "engine_getPayloadBodiesByHashV1", len(args.BlockHashes), false, func(missing []int) []interface{} {
+		hashes := make([]eth.Hash, len(missing))
+		for i, index := range missing {
+			hashes[i] = args.BlockHashes[index]
+		}
+
+		return []interface{}{hashes}
+	})
+	if err != nil {
+		return err
+	}
+
+	*reply = bodies
+	return nil
+}
+
+func (e *EngineService) GetPayloadBodiesByRangeV1(r *http.Request, args *GetPayloadBodiesByRangeV1Args, reply *[]*ExecutionPayloadBodyV1Args) error {
+	if args.Start < 1 || args.Count < 1 {
+		return &json2.Error{Code: json2.E_BAD_PARAMS, Message: "start and count must both be greater than 0"}
+	}
+
+	if args.Count > maxPayloadBodiesRequest {
+		return &json2.Error{Code: EngineErrTooLargeRequest, Message: fmt.Sprintf("Too large request, %d bodies requested, maximum is %d", uint64(args.Count), maxPayloadBodiesRequest)}
+	}
+
+	bodies, err := e.getPayloadBodies(r, "engine_getPayloadBodiesByRangeV1", int(args.Count), true, func(missing []int) []interface{} {
+		// Missing indexes are sorted, we re-request the smallest range covering all of them
+		first, last := missing[0], missing[len(missing)-1]
+		return []interface{}{args.Start + eth.Uint64(first), eth.Uint64(last - first + 1)}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Bodies past the latest block known by the upstreams are not part of the response
+	for len(bodies) > 0 && bodies[len(bodies)-1] == nil {
+		bodies = bodies[:len(bodies)-1]
+	}
+
+	*reply = bodies
+	return nil
+}
+
+// getPayloadBodies queries the upstreams from the healthiest to the least healthy one until
+// all `count` bodies are found. Each upstream is only asked for the bodies still missing,
+// `paramsFor` receives their sorted indexes and must return the params of a request whose
+// answer starts with the first missing body. When `ranged` is true the answer covers the
+// whole range from the first to the last missing body, otherwise it has one entry per
+// missing body. Bodies that no upstream has are left nil.
+func (e *EngineService) getPayloadBodies(r *http.Request, method string, count int, ranged bool, paramsFor func(missing []int) []interface{}) ([]*ExecutionPayloadBodyV1Args, error) {
+	ctx := r.Context()
+	zlogger := logging.Logger(ctx, zlog)
+
+	if len(e.upstreams) == 0 {
+		return nil, &json2.Error{Code: json2.E_SERVER, Message: "no upstream execution node configured"}
+	}
+
+	bodies := make([]*ExecutionPayloadBodyV1Args, count)
+	missing := make([]int, count)
+	for i := range missing {
+		missing[i] = i
+	}
+
+	answered := 0
+	for _, node := range e.health.Ranked(e.upstreams) {
+		if len(missing) == 0 {
+			break
+		}
+
+		found, err := e.fetchPayloadBodies(ctx, node, method, paramsFor(missing))
+		if err != nil {
+			zlogger.Warn("upstream call failed", zap.String("method", method), zap.Stringer("upstream", node), zap.Error(err))
+			continue
+		}
+		answered++
+
+		first := missing[0]
+		var stillMissing []int
+		for i, index := range missing {
+			position := i
+			if ranged {
+				position = index - first
+			}
+
+			if position < len(found) && found[position] != nil {
+				bodies[index] = found[position]
+				continue
+			}
+
+			stillMissing = append(stillMissing, index)
+		}
+
+		zlogger.Debug("upstream payload bodies", zap.String("method", method), zap.Stringer("upstream", node), zap.Int("found", len(missing)-len(stillMissing)), zap.Int("missing", len(stillMissing)))
+		missing = stillMissing
+	}
+
+	if answered == 0 {
+		return nil, &json2.Error{Code: json2.E_SERVER, Message: fmt.Sprintf("all %d upstream execution nodes failed", len(e.upstreams))}
+	}
+
+	return bodies, nil
+}
+
+func (e *EngineService) fetchPayloadBodies(ctx context.Context, node *upstream.Client, method string, params []interface{}) ([]*ExecutionPayloadBodyV1Args, error) {
+	ctx, cancel := context.WithTimeout(ctx, upstreamCallTimeout)
+	defer cancel()
+
+	content, err := node.DoRequest(ctx, method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var bodies []*ExecutionPayloadBodyV1Args
+	if err := json.Unmarshal(content, &bodies); err != nil {
+		return nil, fmt.Errorf("invalid payload bodies: %w", err)
+	}
+
+	return bodies, nil
+}
+
+func (a *GetPayloadBodiesByHashV1Args) Validate(requestInfo *rpc.RequestInfo) error {
+	return nil
+}
+
+func (a *GetPayloadBodiesByRangeV1Args) Validate(requestInfo *rpc.RequestInfo) error {
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// lastForkchoice is the last forkchoice state accepted as VALID along with the method it was
+// received with. It's persisted to disk when a path is configured so that it survives a
+// restart of the proxy.
+type lastForkchoice struct {
+	path string
+
+	lock  sync.Mutex
+	state *persistedForkchoice
+}
+
+type persistedForkchoice struct {
+	Method    string                `json:"method"`
+	State     ForkchoiceStateV1Args `json:"state"`
+	UpdatedAt time.Time             `json:"updatedAt"`
+}
+
+// newLastForkchoice loads the state persisted at `path`, an empty path disables persistence.
+// A missing or unreadable file is not an error, the state is learned from the next
+// forkchoice update of the consensus client.
+func newLastForkchoice(path string) *lastForkchoice {
+	f := &lastForkchoice{path: path}
+	if path == "" {
+		return f
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			zlog.Warn("unable to read last forkchoice state, starting without it", zap.String("path", path), zap.Error(err))
+		}
+
+		return f
+	}
+
+	state := &persistedForkchoice{}
+	if err := json.Unmarshal(content, state); err != nil {
+		zlog.Warn("unable to decode last forkchoice state, starting without it", zap.String("path", path), zap.Error(err))
+		return f
+	}
+
+	zlog.Info("loaded last forkchoice state", zap.String("path", path), zap.String("method", state.Method), zap.Stringer("head_block_hash", state.State.HeadBlockHash), zap.Time("updated_at", state.UpdatedAt))
+	f.state = state
+
+	return f
+}
+
+// Get returns the last forkchoice state and its method, a nil state is returned if none
+// is known yet.
+func (f *lastForkchoice) Get() (method string, state *ForkchoiceStateV1Args) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.state == nil {
+		return "", nil
+	}
+
+	copied := f.state.State
+	return f.state.Method, &copied
+}
+
+// Set records `state` as the last forkchoice state and persists it. Persistence failures
+// are logged, the state is still kept in memory.
+func (f *lastForkchoice) Set(method string, state ForkchoiceStateV1Args) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.state = &persistedForkchoice{Method: method, State: state, UpdatedAt: time.Now().UTC()}
+	if f.path == "" {
+		return
+	}
+
+	if err := f.persist(); err != nil {
+		zlog.Warn("unable to persist last forkchoice state", zap.String("path", f.path), zap.Error(err))
+	}
+}
+
+// persist atomically replaces the file at `path`, it must be called with the lock held.
+func (f *lastForkchoice) persist() error {
+	content, err := json.Marshal(f.state)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("write temporary file: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("close temporary file: %w", err)
+	}
+
+	return os.Rename(tmpFile.Name(), f.path)
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/streamingfast/eth-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastForkchoice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forkchoice.json")
+
+	forkchoice := newLastForkchoice(path)
+	method, state := forkchoice.Get()
+	assert.Nil(t, state)
+
+	expected := ForkchoiceStateV1Args{
+		HeadBlockHash:      eth.MustNewHash("0x01"),
+		SafeBlockHash:      eth.MustNewHash("0x02"),
+		FinalizedBlockHash: eth.MustNewHash("0x03"),
+	}
+	forkchoice.Set("engine_forkchoiceUpdatedV2", expected)
+
+	method, state = newLastForkchoice(path).Get()
+	require.NotNil(t, state)
+	assert.Equal(t, "engine_forkchoiceUpdatedV2", method)
+	assert.Equal(t, expected, *state)
+
+	method, state = newLastForkchoice("").Get()
+	assert.Equal(t, "", method)
+	assert.Nil(t, state)
+}
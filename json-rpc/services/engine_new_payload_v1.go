@@ -15,17 +15,94 @@
 package services
 
 import (
-	"go.uber.org/zap"
+	"encoding/json"
+	"fmt"
 	"net/http"
 
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/streamingfast/eth-go"
 	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
 )
 
 func (e *EngineService) NewPayloadV1(r *http.Request, arg *ExecutionPayloadV1Args, reply *PayloadStatusV1Args) error {
+	if err := e.checkFork(arg.Timestamp, engineForkParis, engineForkParis); err != nil {
+		return err
+	}
+
+	return e.newPayload(r, "engine_newPayloadV1", arg.BlockHash, arg.BlockNumber, arg, reply, arg)
+}
+
+// newPayload forwards the payload to all upstreams and merges their statuses in `reply`,
+// it's shared by all versions of `engine_newPayload` which only differ in their params. The
+// block hash is verified locally first, a payload with a wrong hash never reaches upstreams.
+func (e *EngineService) newPayload(r *http.Request, method string, blockHash eth.Hash, blockNumber eth.Uint64, payload verifiablePayload, reply *PayloadStatusV1Args, params ...interface{}) error {
 	ctx := r.Context()
 	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("New payload v1 :", zap.Reflect("args", arg))
-	zlogger.Info("New payload v1 :", zap.Reflect("reply", reply))
+	zlogger.Debug("new payload", zap.String("method", method), zap.Stringer("block_hash", blockHash), zap.Uint64("block_number", uint64(blockNumber)))
+
+	if status := verifyBlockHash(blockHash, payload); status != nil {
+		zlogger.Warn("rejecting payload failing local verification", zap.String("method", method), zap.Stringer("block_hash", blockHash), zap.Uint64("block_number", uint64(blockNumber)), zap.String("status", string(status.Status)), zap.Stringp("validation_error", status.ValidationError))
+		*reply = *status
+		return nil
+	}
+
+	seq := e.record(method, params...)
+
+	votes, err := e.collectPayloadStatuses(r, method, params...)
+	if err != nil {
+		return err
+	}
+
+	e.backlog.Track(seq, votes)
+	e.detectDivergence(ctx, method, blockHash, blockNumber, votes, params)
+
+	*reply = *e.mergeVotes(ctx, method, votes)
+	e.journalCall(ctx, method, reply.Status, params...)
+	zlogger.Debug("new payload completed", zap.String("method", method), zap.String("status", string(reply.Status)), zap.Int("upstream_count", len(votes)))
 
 	return nil
 }
+
+// collectPayloadStatuses fans out the request to all upstreams and decodes each successful
+// answer as a `PayloadStatusV1`. Upstreams that failed are logged and vote with a nil status,
+// an error is returned only if not a single upstream was able to answer.
+func (e *EngineService) collectPayloadStatuses(r *http.Request, method string, params ...interface{}) (payloadStatusVotes, error) {
+	zlogger := logging.Logger(r.Context(), zlog)
+
+	if len(e.upstreams) == 0 {
+		return nil, &json2.Error{Code: json2.E_SERVER, Message: "no upstream execution node configured"}
+	}
+
+	var votes payloadStatusVotes
+	answered := 0
+	for _, response := range e.fanOut(r.Context(), method, params...) {
+		vote := &payloadStatusVote{upstream: response.upstream, content: response.content, err: response.err}
+		votes = append(votes, vote)
+
+		if response.err != nil {
+			zlogger.Warn("upstream call failed", zap.String("method", method), zap.Stringer("upstream", response.upstream), zap.Error(response.err))
+			e.health.Record(response.upstream, nil, response.err)
+			continue
+		}
+
+		status := &PayloadStatusV1Args{}
+		if err := json.Unmarshal(response.content, status); err != nil {
+			zlogger.Warn("upstream returned an invalid payload status", zap.String("method", method), zap.Stringer("upstream", response.upstream), zap.ByteString("content", response.content), zap.Error(err))
+			e.health.Record(response.upstream, nil, err)
+			vote.err = err
+			continue
+		}
+
+		e.health.Record(response.upstream, status, nil)
+
+		vote.status = status
+		answered++
+	}
+
+	if answered == 0 {
+		return nil, &json2.Error{Code: json2.E_SERVER, Message: fmt.Sprintf("all %d upstream execution nodes failed", len(e.upstreams))}
+	}
+
+	return votes, nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"net/http"
+)
+
+// NewPayloadV2 accepts both `ExecutionPayloadV1` and `ExecutionPayloadV2`, the former being
+// distinguished by the absence of withdrawals.
+func (e *EngineService) NewPayloadV2(r *http.Request, arg *ExecutionPayloadV2Args, reply *PayloadStatusV1Args) error {
+	if err := e.checkFork(arg.Timestamp, engineForkParis, engineForkShanghai); err != nil {
+		return err
+	}
+
+	if err := e.checkWithdrawals(arg.Timestamp, arg.Withdrawals); err != nil {
+		return err
+	}
+
+	return e.newPayload(r, "engine_newPayloadV2", arg.BlockHash, arg.BlockNumber, arg, reply, arg)
+}
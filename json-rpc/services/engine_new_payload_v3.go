@@ -0,0 +1,46 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/streamingfast/eth-go"
+)
+
+type NewPayloadV3Args struct {
+	ExecutionPayload            ExecutionPayloadV3Args `json:"executionPayload"`
+	ExpectedBlobVersionedHashes []eth.Hash             `json:"expectedBlobVersionedHashes"`
+	ParentBeaconBlockRoot       eth.Hash               `json:"parentBeaconBlockRoot"`
+}
+
+func (e *EngineService) NewPayloadV3(r *http.Request, args *NewPayloadV3Args, reply *PayloadStatusV1Args) error {
+	payload := &args.ExecutionPayload
+	if payload.Withdrawals == nil || args.ExpectedBlobVersionedHashes == nil || args.ParentBeaconBlockRoot == nil {
+		return &json2.Error{Code: json2.E_BAD_PARAMS, Message: "withdrawals, expectedBlobVersionedHashes and parentBeaconBlockRoot are required"}
+	}
+
+	if err := e.checkFork(payload.Timestamp, engineForkCancun, engineForkCancun); err != nil {
+		return err
+	}
+
+	return e.newPayload(r, "engine_newPayloadV3", payload.BlockHash, payload.BlockNumber, args, reply, payload, args.ExpectedBlobVersionedHashes, args.ParentBeaconBlockRoot)
+}
+
+func (a *NewPayloadV3Args) Validate(requestInfo *rpc.RequestInfo) error {
+	return nil
+}
@@ -17,25 +17,12 @@ package services
 import (
 	"github.com/gorilla/rpc/v2"
 	"github.com/streamingfast/eth-go"
-	"go.uber.org/zap"
-	"net/http"
-
-	"github.com/streamingfast/logging"
 )
 
 type PayloadAttributesV1Args struct {
 	Timestamp             eth.Uint64  `json:"timestamp"`
 	PrevRandao            eth.Hash    `json:"prevRandao"`
-	SuggestedFeeRecipient eth.Address `json:"SuggestedFeeRecipient"`
-}
-
-func (e *EngineService) PayloadAttributesV1(r *http.Request, args *PayloadAttributesV1Args, reply *eth.Hex) error {
-	ctx := r.Context()
-	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("engine payload attributes v1 args:", zap.Reflect("args", args))
-	zlogger.Info("engine payload attributes v1 reply:", zap.Reflect("reply", reply))
-
-	return nil
+	SuggestedFeeRecipient eth.Address `json:"suggestedFeeRecipient"`
 }
 
 func (e *PayloadAttributesV1Args) Validate(requestInfo *rpc.RequestInfo) error {
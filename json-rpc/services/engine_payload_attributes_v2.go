@@ -17,28 +17,15 @@ package services
 import (
 	"github.com/gorilla/rpc/v2"
 	"github.com/streamingfast/eth-go"
-	"go.uber.org/zap"
-	"net/http"
-
-	"github.com/streamingfast/logging"
 )
 
 type PayloadAttributesV2Args struct {
 	Timestamp             eth.Uint64         `json:"timestamp"`
 	PrevRandao            eth.Hash           `json:"prevRandao"`
-	SuggestedFeeRecipient eth.Address        `json:"SuggestedFeeRecipient"`
+	SuggestedFeeRecipient eth.Address        `json:"suggestedFeeRecipient"`
 	Withdrawals           []WithdrawalV1Args `json:"withdrawals"`
 }
 
-func (e *EngineService) PayloadAttributesV2(r *http.Request, args *PayloadAttributesV2Args, reply *eth.Hex) error {
-	ctx := r.Context()
-	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("engine payload attributes v2 :", zap.Reflect("args", args))
-
-	*reply = eth.MustNewHex("ab")
-	return nil
-}
-
 func (e *PayloadAttributesV2Args) Validate(requestInfo *rpc.RequestInfo) error {
 	return nil
 }
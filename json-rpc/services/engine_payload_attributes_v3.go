@@ -0,0 +1,32 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"github.com/gorilla/rpc/v2"
+	"github.com/streamingfast/eth-go"
+)
+
+type PayloadAttributesV3Args struct {
+	Timestamp             eth.Uint64         `json:"timestamp"`
+	PrevRandao            eth.Hash           `json:"prevRandao"`
+	SuggestedFeeRecipient eth.Address        `json:"suggestedFeeRecipient"`
+	Withdrawals           []WithdrawalV1Args `json:"withdrawals"`
+	ParentBeaconBlockRoot eth.Hash           `json:"parentBeaconBlockRoot"`
+}
+
+func (e *PayloadAttributesV3Args) Validate(requestInfo *rpc.RequestInfo) error {
+	return nil
+}
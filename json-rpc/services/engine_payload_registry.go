@@ -0,0 +1,75 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+)
+
+// payloadIDRetention is how long we remember which upstream built a payload, execution
+// clients themselves only keep built payloads for a few slots.
+const payloadIDRetention = 2 * time.Minute
+
+// payloadRegistry keeps track of the upstream that returned each payload id so that
+// `engine_getPayload*` calls are routed to the node actually building the payload.
+type payloadRegistry struct {
+	lock    sync.Mutex
+	entries map[string]*payloadRegistryEntry
+}
+
+type payloadRegistryEntry struct {
+	upstream *upstream.Client
+
+	// timestamp is the timestamp of the payload being built as given in the payload
+	// attributes, it determines which `engine_getPayload` version may retrieve it.
+	timestamp eth.Uint64
+	expiresAt time.Time
+}
+
+func newPayloadRegistry() *payloadRegistry {
+	return &payloadRegistry{
+		entries: make(map[string]*payloadRegistryEntry),
+	}
+}
+
+func (r *payloadRegistry) Add(payloadID eth.Hex, node *upstream.Client, timestamp eth.Uint64, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for id, entry := range r.entries {
+		if now.After(entry.expiresAt) {
+			delete(r.entries, id)
+		}
+	}
+
+	r.entries[payloadID.String()] = &payloadRegistryEntry{upstream: node, timestamp: timestamp, expiresAt: now.Add(payloadIDRetention)}
+}
+
+// Get returns the entry of the upstream that built `payloadID` or nil if it's unknown or expired.
+func (r *payloadRegistry) Get(payloadID eth.Hex, now time.Time) *payloadRegistryEntry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry, found := r.entries[payloadID.String()]
+	if !found || now.After(entry.expiresAt) {
+		return nil
+	}
+
+	return entry
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayloadRegistry(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	nodeA := upstream.NewClient("http://a", nil)
+	nodeB := upstream.NewClient("http://b", nil)
+
+	registry := newPayloadRegistry()
+	registry.Add(eth.MustNewHex("0x01"), nodeA, 10, now)
+	registry.Add(eth.MustNewHex("0x02"), nodeB, 22, now.Add(time.Minute))
+
+	assert.Equal(t, &payloadRegistryEntry{upstream: nodeA, timestamp: 10, expiresAt: now.Add(payloadIDRetention)}, registry.Get(eth.MustNewHex("0x01"), now))
+	assert.Equal(t, nodeB, registry.Get(eth.MustNewHex("0x02"), now).upstream)
+	assert.Nil(t, registry.Get(eth.MustNewHex("0x03"), now))
+
+	assert.Nil(t, registry.Get(eth.MustNewHex("0x01"), now.Add(payloadIDRetention+time.Second)), "expired")
+	assert.Equal(t, nodeB, registry.Get(eth.MustNewHex("0x02"), now.Add(payloadIDRetention+time.Second)).upstream)
+}
@@ -17,10 +17,6 @@ package services
 import (
 	"github.com/gorilla/rpc/v2"
 	"github.com/streamingfast/eth-go"
-	"go.uber.org/zap"
-	"net/http"
-
-	"github.com/streamingfast/logging"
 )
 
 type EnginePayloadStatus string
@@ -35,17 +31,32 @@ const (
 
 type PayloadStatusV1Args struct {
 	Status          EnginePayloadStatus `json:"status"`
-	LatestValidHash eth.Hash            `json:"latestValidHash"`
-	ValidationError string              `json:"validationError"`
+	LatestValidHash *eth.Hash           `json:"latestValidHash"`
+	ValidationError *string             `json:"validationError"`
+}
+
+// payloadStatusPriority defines which status wins when upstream nodes disagree, a single
+// VALID answer is enough for the consensus client to move forward while SYNCING is only
+// returned when no node knows better.
+var payloadStatusPriority = map[EnginePayloadStatus]int{
+	EnginePayloadStatusValid:            5,
+	EnginePayloadStatusInvalid:          4,
+	EnginePayloadStatusInvalidBlockHash: 3,
+	EnginePayloadStatusAccepted:         2,
+	EnginePayloadStatusSyncing:          1,
 }
 
-func (e *EngineService) PayloadStatusV1(r *http.Request, args *PayloadStatusV1Args, reply *eth.Hex) error {
-	ctx := r.Context()
-	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("engine payload status v1 :", zap.Reflect("args", args))
+// mergePayloadStatuses reduces the statuses received from the upstream nodes into the single
+// status returned to the consensus client. The slice must contain at least one element.
+func mergePayloadStatuses(statuses []*PayloadStatusV1Args) *PayloadStatusV1Args {
+	merged := statuses[0]
+	for _, status := range statuses[1:] {
+		if payloadStatusPriority[status.Status] > payloadStatusPriority[merged.Status] {
+			merged = status
+		}
+	}
 
-	*reply = eth.MustNewHex("ab")
-	return nil
+	return merged
 }
 
 func (e *PayloadStatusV1Args) Validate(requestInfo *rpc.RequestInfo) error {
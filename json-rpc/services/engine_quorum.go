@@ -0,0 +1,160 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// QuorumPolicy decides how the payload statuses answered by the upstreams are reduced to the
+// single status returned to the consensus client. Whenever a policy can't reach a decision,
+// SYNCING is returned which tells the consensus client to proceed optimistically.
+type QuorumPolicy string
+
+const (
+	// QuorumPolicyPrimary answers with the status of the builder node alone.
+	QuorumPolicyPrimary QuorumPolicy = "primary"
+
+	// QuorumPolicyAnyValid answers with the most conclusive status, a single VALID is enough.
+	QuorumPolicyAnyValid QuorumPolicy = "any-valid"
+
+	// QuorumPolicyMajority answers with the status shared by more than half of the upstreams.
+	QuorumPolicyMajority QuorumPolicy = "majority"
+
+	// QuorumPolicyAll answers with the status only if all upstreams agree on it.
+	QuorumPolicyAll QuorumPolicy = "all"
+)
+
+func ParseQuorumPolicy(in string) (QuorumPolicy, error) {
+	switch policy := QuorumPolicy(in); policy {
+	case QuorumPolicyPrimary, QuorumPolicyAnyValid, QuorumPolicyMajority, QuorumPolicyAll:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown quorum policy %q, valid policies are %s, %s, %s and %s", in, QuorumPolicyPrimary, QuorumPolicyAnyValid, QuorumPolicyMajority, QuorumPolicyAll)
+	}
+}
+
+// payloadStatusVote is the payload status answered by an upstream, `status` is nil and `err`
+// is set when the upstream failed to answer. `content` is the raw answer of the upstream.
+type payloadStatusVote struct {
+	upstream *upstream.Client
+	status   *PayloadStatusV1Args
+	content  json.RawMessage
+	err      error
+}
+
+func (v *payloadStatusVote) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("upstream", v.upstream.String())
+	if v.status == nil {
+		enc.AddString("status", "FAILED")
+		return nil
+	}
+
+	enc.AddString("status", string(v.status.Status))
+	if v.status.ValidationError != nil {
+		enc.AddString("validation_error", *v.status.ValidationError)
+	}
+
+	return nil
+}
+
+type payloadStatusVotes []*payloadStatusVote
+
+func (v payloadStatusVotes) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, vote := range v {
+		enc.AppendObject(vote)
+	}
+
+	return nil
+}
+
+// unanimous returns true if all upstreams answered with the same status.
+func (v payloadStatusVotes) unanimous() bool {
+	for _, vote := range v {
+		if vote.status == nil || vote.status.Status != v[0].status.Status {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mergeVotes reduces the votes of the upstreams to a single status according to the quorum
+// policy, the decision is logged along with every vote.
+func (e *EngineService) mergeVotes(ctx context.Context, method string, votes payloadStatusVotes) *PayloadStatusV1Args {
+	status := e.quorum.merge(votes, e.builder)
+
+	zlogger := logging.Logger(ctx, zlog)
+	level := zapcore.DebugLevel
+	if !votes.unanimous() {
+		level = zapcore.InfoLevel
+	}
+
+	if ce := zlogger.Check(level, "payload status votes merged"); ce != nil {
+		ce.Write(zap.String("method", method), zap.String("quorum_policy", string(e.quorum)), zap.Array("votes", votes), zap.String("status", string(status.Status)))
+	}
+
+	return status
+}
+
+func (p QuorumPolicy) merge(votes payloadStatusVotes, primary *upstream.Client) *PayloadStatusV1Args {
+	switch p {
+	case QuorumPolicyPrimary:
+		for _, vote := range votes {
+			if vote.upstream == primary && vote.status != nil {
+				return vote.status
+			}
+		}
+
+	case QuorumPolicyMajority:
+		counts := map[EnginePayloadStatus]int{}
+		for _, vote := range votes {
+			if vote.status == nil {
+				continue
+			}
+
+			counts[vote.status.Status]++
+			if counts[vote.status.Status]*2 > len(votes) {
+				return vote.status
+			}
+		}
+
+	case QuorumPolicyAll:
+		if votes.unanimous() {
+			return votes[0].status
+		}
+
+	default:
+		var statuses []*PayloadStatusV1Args
+		for _, vote := range votes {
+			if vote.status != nil {
+				statuses = append(statuses, vote.status)
+			}
+		}
+
+		if len(statuses) > 0 {
+			return mergePayloadStatuses(statuses)
+		}
+	}
+
+	return &PayloadStatusV1Args{Status: EnginePayloadStatusSyncing}
+}
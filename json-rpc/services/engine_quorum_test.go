@@ -0,0 +1,71 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"testing"
+
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuorumPolicyMerge(t *testing.T) {
+	nodeA := upstream.NewClient("http://a", nil)
+	nodeB := upstream.NewClient("http://b", nil)
+	nodeC := upstream.NewClient("http://c", nil)
+
+	votes := func(statuses ...EnginePayloadStatus) (out payloadStatusVotes) {
+		for i, node := range []*upstream.Client{nodeA, nodeB, nodeC} {
+			vote := &payloadStatusVote{upstream: node}
+			if statuses[i] != "" {
+				vote.status = &PayloadStatusV1Args{Status: statuses[i]}
+			}
+
+			out = append(out, vote)
+		}
+		return
+	}
+
+	const (
+		valid   = EnginePayloadStatusValid
+		invalid = EnginePayloadStatusInvalid
+		syncing = EnginePayloadStatusSyncing
+		failed  = EnginePayloadStatus("")
+	)
+
+	tests := []struct {
+		name     string
+		policy   QuorumPolicy
+		votes    payloadStatusVotes
+		expected EnginePayloadStatus
+	}{
+		{"primary uses builder", QuorumPolicyPrimary, votes(syncing, invalid, valid), invalid},
+		{"primary failed", QuorumPolicyPrimary, votes(valid, failed, valid), syncing},
+		{"any-valid single valid", QuorumPolicyAnyValid, votes(syncing, failed, valid), valid},
+		{"any-valid invalid over syncing", QuorumPolicyAnyValid, votes(syncing, invalid, syncing), invalid},
+		{"majority reached", QuorumPolicyMajority, votes(valid, syncing, valid), valid},
+		{"majority counts failures", QuorumPolicyMajority, votes(valid, failed, syncing), syncing},
+		{"majority invalid", QuorumPolicyMajority, votes(invalid, invalid, valid), invalid},
+		{"all agree", QuorumPolicyAll, votes(valid, valid, valid), valid},
+		{"all disagree", QuorumPolicyAll, votes(valid, valid, invalid), syncing},
+		{"all with failure", QuorumPolicyAll, votes(valid, valid, failed), syncing},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.policy.merge(test.votes, nodeB).Status)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/config"
+	"github.com/streamingfast/geth-proxy/json-rpc/divergence"
+	"github.com/streamingfast/geth-proxy/json-rpc/enginetest"
+	"github.com/streamingfast/geth-proxy/json-rpc/journal"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineService_FanOut(t *testing.T) {
+	chainConfig, err := config.NetworkNameToChainConfig("battlefield")
+	require.NoError(t, err)
+
+	var engines []*enginetest.Engine
+	var upstreams []*upstream.Client
+	for i := 0; i < 3; i++ {
+		engine := enginetest.NewEngine(1337)
+		server := httptest.NewServer(engine)
+		defer server.Close()
+
+		engines = append(engines, engine)
+		upstreams = append(upstreams, upstream.NewClient(server.URL, nil))
+	}
+
+	newService := func(quorum services.QuorumPolicy) *services.EngineService {
+		return services.NewEngineService(chainConfig, upstreams, upstreams[0], quorum, nil, nil, "", nil)
+	}
+	request := httptest.NewRequest("POST", "/", nil)
+	genesis := engines[0].Genesis().Hash
+
+	service := newService(services.QuorumPolicyAnyValid)
+
+	fcuReply := &services.ForkchoiceUpdatedV1Reply{}
+	require.NoError(t, service.ForkchoiceUpdatedV1(request, &services.ForkchoiceUpdatedV1Args{
+		ForkchoiceState:   services.ForkchoiceStateV1Args{HeadBlockHash: genesis, SafeBlockHash: genesis, FinalizedBlockHash: genesis},
+		PayloadAttributes: &services.PayloadAttributesV1Args{Timestamp: 12, PrevRandao: eth.MustNewHash("0x01"), SuggestedFeeRecipient: eth.MustNewAddress("0x0000000000000000000000000000000000000002")},
+	}, fcuReply))
+	require.NotNil(t, fcuReply.PayloadID)
+	assert.Equal(t, 1, engines[0].CallCount("engine_forkchoiceUpdatedV1"))
+	assert.Equal(t, 1, engines[2].CallCount("engine_forkchoiceUpdatedV1"))
+
+	payload := &services.ExecutionPayloadV1Args{}
+	require.NoError(t, service.GetPayloadV1(request, &services.GetPayloadArgs{PayloadID: *fcuReply.PayloadID}, payload), "payload id is routed to the builder")
+
+	engines[1].SetOffline(true)
+	engines[2].ScriptBlock(1, enginetest.Syncing())
+
+	status := &services.PayloadStatusV1Args{}
+	require.NoError(t, service.NewPayloadV1(request, payload, status))
+	assert.Equal(t, services.EnginePayloadStatusValid, status.Status)
+
+	require.NoError(t, newService(services.QuorumPolicyAll).NewPayloadV1(request, payload, status))
+	assert.Equal(t, services.EnginePayloadStatusSyncing, status.Status)
+
+	engines[1].SetOffline(false)
+	engines[2].ScriptBlock(1, enginetest.Invalid(genesis, "bad state root"))
+	require.NoError(t, newService(services.QuorumPolicyMajority).NewPayloadV1(request, payload, status))
+	assert.Equal(t, services.EnginePayloadStatusValid, status.Status)
+}
+
+func TestEngineService_ForkchoiceUpdatedBuilderError(t *testing.T) {
+	chainConfig, err := config.NetworkNameToChainConfig("battlefield")
+	require.NoError(t, err)
+
+	var engines []*enginetest.Engine
+	var upstreams []*upstream.Client
+	for i := 0; i < 2; i++ {
+		engine := enginetest.NewEngine(1337)
+		server := httptest.NewServer(engine)
+		defer server.Close()
+
+		engines = append(engines, engine)
+		upstreams = append(upstreams, upstream.NewClient(server.URL, nil))
+	}
+
+	service := services.NewEngineService(chainConfig, upstreams, upstreams[0], services.QuorumPolicyAnyValid, nil, nil, "", nil)
+	request := httptest.NewRequest("POST", "/", nil)
+	genesis := engines[0].Genesis().Hash
+
+	engines[0].ScriptError("engine_forkchoiceUpdatedV1", int(services.EngineErrInvalidPayloadAttributes), "Invalid payload attributes")
+
+	fcuReply := &services.ForkchoiceUpdatedV1Reply{}
+	err = service.ForkchoiceUpdatedV1(request, &services.ForkchoiceUpdatedV1Args{
+		ForkchoiceState:   services.ForkchoiceStateV1Args{HeadBlockHash: genesis, SafeBlockHash: genesis, FinalizedBlockHash: genesis},
+		PayloadAttributes: &services.PayloadAttributesV1Args{Timestamp: 12, PrevRandao: eth.MustNewHash("0x01"), SuggestedFeeRecipient: eth.MustNewAddress("0x0000000000000000000000000000000000000002")},
+	}, fcuReply)
+
+	var rpcErr *json2.Error
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, services.EngineErrInvalidPayloadAttributes, rpcErr.Code)
+	assert.Equal(t, "Invalid payload attributes", rpcErr.Message)
+}
+
+func TestEngineService_DivergenceReport(t *testing.T) {
+	ctx := context.Background()
+	chainConfig, err := config.NetworkNameToChainConfig("battlefield")
+	require.NoError(t, err)
+
+	var engines []*enginetest.Engine
+	var upstreams []*upstream.Client
+	for i := 0; i < 2; i++ {
+		engine := enginetest.NewEngine(1337)
+		server := httptest.NewServer(engine)
+		defer server.Close()
+
+		engines = append(engines, engine)
+		upstreams = append(upstreams, upstream.NewClient(server.URL, nil))
+	}
+
+	store, err := divergence.NewStore("file://" + t.TempDir())
+	require.NoError(t, err)
+
+	service := services.NewEngineService(chainConfig, upstreams, upstreams[0], services.QuorumPolicyPrimary, store, nil, "", nil)
+	request := httptest.NewRequest("POST", "/", nil)
+	genesis := engines[0].Genesis().Hash
+
+	fcuReply := &services.ForkchoiceUpdatedV1Reply{}
+	require.NoError(t, service.ForkchoiceUpdatedV1(request, &services.ForkchoiceUpdatedV1Args{
+		ForkchoiceState:   services.ForkchoiceStateV1Args{HeadBlockHash: genesis, SafeBlockHash: genesis, FinalizedBlockHash: genesis},
+		PayloadAttributes: &services.PayloadAttributesV1Args{Timestamp: 12, PrevRandao: eth.MustNewHash("0x01"), SuggestedFeeRecipient: eth.MustNewAddress("0x0000000000000000000000000000000000000002")},
+	}, fcuReply))
+	require.NotNil(t, fcuReply.PayloadID)
+
+	payload := &services.ExecutionPayloadV1Args{}
+	require.NoError(t, service.GetPayloadV1(request, &services.GetPayloadArgs{PayloadID: *fcuReply.PayloadID}, payload))
+
+	engines[1].ScriptBlock(1, enginetest.Invalid(genesis, "bad state root"))
+	status := &services.PayloadStatusV1Args{}
+	require.NoError(t, service.NewPayloadV1(request, payload, status))
+	assert.Equal(t, services.EnginePayloadStatusValid, status.Status)
+
+	var names []string
+	require.Eventually(t, func() bool {
+		names, err = store.List(ctx)
+		return err == nil && len(names) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	report, err := store.Get(ctx, names[0])
+	require.NoError(t, err)
+	require.Len(t, report.Responses, 2)
+	for _, response := range report.Responses {
+		assert.Equal(t, "enginetest/v0.0.0-00000000 (ET)", response.ClientVersion)
+	}
+}
+
+func TestEngineService_JournalAcceptedCalls(t *testing.T) {
+	chainConfig, err := config.NetworkNameToChainConfig("battlefield")
+	require.NoError(t, err)
+
+	engine := enginetest.NewEngine(1337)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+	upstreams := []*upstream.Client{upstream.NewClient(server.URL, nil)}
+
+	storeURL := "file://" + t.TempDir()
+	calls, err := journal.NewWriter(storeURL)
+	require.NoError(t, err)
+
+	service := services.NewEngineService(chainConfig, upstreams, upstreams[0], services.QuorumPolicyPrimary, nil, calls, "", nil)
+	request := httptest.NewRequest("POST", "/", nil)
+	genesis := engine.Genesis().Hash
+
+	fcuReply := &services.ForkchoiceUpdatedV1Reply{}
+	require.NoError(t, service.ForkchoiceUpdatedV1(request, &services.ForkchoiceUpdatedV1Args{
+		ForkchoiceState:   services.ForkchoiceStateV1Args{HeadBlockHash: genesis, SafeBlockHash: genesis, FinalizedBlockHash: genesis},
+		PayloadAttributes: &services.PayloadAttributesV1Args{Timestamp: 12, PrevRandao: eth.MustNewHash("0x01"), SuggestedFeeRecipient: eth.MustNewAddress("0x0000000000000000000000000000000000000002")},
+	}, fcuReply))
+	require.NotNil(t, fcuReply.PayloadID)
+
+	payload := &services.ExecutionPayloadV1Args{}
+	require.NoError(t, service.GetPayloadV1(request, &services.GetPayloadArgs{PayloadID: *fcuReply.PayloadID}, payload))
+
+	engine.ScriptBlock(1, enginetest.Invalid(genesis, "bad state root"))
+	status := &services.PayloadStatusV1Args{}
+	require.NoError(t, service.NewPayloadV1(request, payload, status))
+	assert.Equal(t, services.EnginePayloadStatusInvalid, status.Status)
+
+	engine.ResetScripts()
+	require.NoError(t, service.NewPayloadV1(request, payload, status))
+	assert.Equal(t, services.EnginePayloadStatusValid, status.Status)
+
+	calls.Shutdown(nil)
+
+	var methods []string
+	require.NoError(t, journal.Read(context.Background(), storeURL, time.Time{}, func(entry *journal.Entry) error {
+		methods = append(methods, entry.Method)
+		return nil
+	}))
+	assert.Equal(t, []string{"engine_forkchoiceUpdatedV1", "engine_newPayloadV1"}, methods, "the rejected payload is not journaled")
+}
@@ -15,12 +15,12 @@
 package services
 
 import (
+	"bytes"
+
 	"github.com/gorilla/rpc/v2"
 	"github.com/streamingfast/eth-go"
-	"go.uber.org/zap"
-	"net/http"
-
-	"github.com/streamingfast/logging"
+	"github.com/streamingfast/geth-proxy/config"
+	"go.uber.org/zap/zapcore"
 )
 
 type TransitionConfigurationV1Args struct {
@@ -29,12 +29,30 @@ type TransitionConfigurationV1Args struct {
 	TerminalBlockNumber     eth.Uint64 `json:"terminalBlockNumber"`
 }
 
-func (e *EngineService) TransitionConfigurationV1(r *http.Request, args *TransitionConfigurationV1Args, reply *eth.Hex) error {
-	ctx := r.Context()
-	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("engine transition configuration v1 :", zap.Reflect("args", args))
+func newTransitionConfigurationV1(chainConfig *config.ChainConfig) *TransitionConfigurationV1Args {
+	out := &TransitionConfigurationV1Args{
+		TerminalBlockHash:   eth.Hash(chainConfig.TerminalBlockHash.Bytes()),
+		TerminalBlockNumber: eth.Uint64(chainConfig.TerminalBlockNumber),
+	}
+
+	if chainConfig.TerminalTotalDifficulty != nil {
+		out.TerminalTotalDifficulty = BigInt(*chainConfig.TerminalTotalDifficulty)
+	}
+
+	return out
+}
+
+func (a *TransitionConfigurationV1Args) Equal(other *TransitionConfigurationV1Args) bool {
+	return a.TerminalTotalDifficulty.Int().Cmp(other.TerminalTotalDifficulty.Int()) == 0 &&
+		bytes.Equal(a.TerminalBlockHash, other.TerminalBlockHash) &&
+		a.TerminalBlockNumber == other.TerminalBlockNumber
+}
+
+func (a *TransitionConfigurationV1Args) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("terminal_total_difficulty", a.TerminalTotalDifficulty.Int().String())
+	enc.AddString("terminal_block_hash", a.TerminalBlockHash.Pretty())
+	enc.AddUint64("terminal_block_number", uint64(a.TerminalBlockNumber))
 
-	*reply = eth.MustNewHex("ab")
 	return nil
 }
 
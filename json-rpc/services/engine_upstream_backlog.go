@@ -0,0 +1,240 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"go.uber.org/zap"
+)
+
+const (
+	// backlogCapacity is the number of recent payload and forkchoice calls retained to catch
+	// up lagging upstreams, about two calls are made per slot so it covers ~25 minutes.
+	backlogCapacity = 256
+
+	// catchUpRetryDelay is the minimum delay between two catch up attempts of the same
+	// upstream, an upstream that can't be caught up from the backlog syncs from its peers.
+	catchUpRetryDelay = 1 * time.Minute
+)
+
+type backlogCall struct {
+	seq    uint64
+	method string
+	params []interface{}
+}
+
+type backlogNode struct {
+	// acknowledged is the sequence of the last call the upstream processed, calls after it
+	// form the upstream's backlog.
+	acknowledged uint64
+	lagging      bool
+	catchingUp   bool
+	lastCatchUp  time.Time
+}
+
+// upstreamBacklog retains the most recent payload and forkchoice calls and tracks, for each
+// upstream, the last call it processed. An upstream that failed or answered SYNCING is
+// lagging, as soon as it answers again the calls it missed are replayed to it in order while
+// the consensus client keeps being served by the other upstreams.
+type upstreamBacklog struct {
+	lock    sync.Mutex
+	calls   []*backlogCall
+	lastSeq uint64
+	nodes   map[*upstream.Client]*backlogNode
+}
+
+func newUpstreamBacklog() *upstreamBacklog {
+	return &upstreamBacklog{
+		nodes: make(map[*upstream.Client]*backlogNode),
+	}
+}
+
+// Append retains a call forwarded to the upstreams and returns its sequence, the oldest
+// call is dropped once the backlog is full.
+func (b *upstreamBacklog) Append(method string, params []interface{}) uint64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.lastSeq++
+	b.calls = append(b.calls, &backlogCall{seq: b.lastSeq, method: method, params: params})
+	if len(b.calls) > backlogCapacity {
+		b.calls = b.calls[len(b.calls)-backlogCapacity:]
+	}
+
+	return b.lastSeq
+}
+
+// Track updates the state of each upstream from its vote on the call `seq` and starts
+// catching up the lagging upstreams that answered again.
+func (b *upstreamBacklog) Track(seq uint64, votes payloadStatusVotes) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, vote := range votes {
+		node := b.node(vote.upstream)
+		if node.catchingUp {
+			// The catch up loop acknowledges calls as they are replayed
+			continue
+		}
+
+		if vote.err != nil {
+			if !node.lagging {
+				zlog.Info("upstream is lagging, retaining the calls it misses", zap.Stringer("upstream", vote.upstream), zap.Uint64("acknowledged", node.acknowledged))
+			}
+
+			node.lagging = true
+			continue
+		}
+
+		if vote.status.Status != EnginePayloadStatusSyncing {
+			if node.lagging {
+				zlog.Info("upstream caught up on its own", zap.Stringer("upstream", vote.upstream))
+			}
+
+			node.acknowledged = seq
+			node.lagging = false
+			continue
+		}
+
+		node.lagging = true
+		if time.Since(node.lastCatchUp) < catchUpRetryDelay {
+			continue
+		}
+
+		node.catchingUp = true
+		node.lastCatchUp = time.Now()
+		go b.catchUp(vote.upstream)
+	}
+}
+
+// catchUp replays to `client` the calls it did not acknowledge, until none are left or the
+// upstream fails or can't process them.
+func (b *upstreamBacklog) catchUp(client *upstream.Client) {
+	zlogger := zlog.With(zap.Stringer("upstream", client))
+
+	replayed := 0
+	for {
+		calls, truncated := b.pending(client)
+		if len(calls) == 0 {
+			zlogger.Info("upstream caught up", zap.Int("replayed", replayed))
+			b.finishCatchUp(client, false)
+			return
+		}
+
+		if replayed == 0 {
+			zlogger.Info("catching up upstream", zap.Int("backlog", len(calls)), zap.Bool("truncated", truncated))
+			if truncated {
+				zlogger.Warn("upstream missed more calls than retained, it will need to sync older blocks from its peers")
+			}
+		}
+
+		for _, call := range calls {
+			status, err := callPayloadStatus(context.Background(), client, call.method, call.params)
+			if err != nil {
+				zlogger.Warn("upstream catch up failed", zap.String("method", call.method), zap.Uint64("seq", call.seq), zap.Error(err))
+				b.finishCatchUp(client, true)
+				return
+			}
+
+			if status.Status == EnginePayloadStatusSyncing {
+				zlogger.Info("upstream is still syncing, stopping catch up", zap.String("method", call.method), zap.Uint64("seq", call.seq), zap.Int("replayed", replayed))
+				b.finishCatchUp(client, true)
+				return
+			}
+
+			b.acknowledge(client, call.seq)
+			replayed++
+		}
+	}
+}
+
+// pending returns the retained calls not acknowledged by `client` and whether some of the
+// calls it missed were already dropped from the backlog.
+func (b *upstreamBacklog) pending(client *upstream.Client) (calls []*backlogCall, truncated bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	acknowledged := b.node(client).acknowledged
+	for _, call := range b.calls {
+		if call.seq > acknowledged {
+			calls = append(calls, call)
+		}
+	}
+
+	truncated = len(calls) > 0 && calls[0].seq > acknowledged+1
+	return
+}
+
+func (b *upstreamBacklog) acknowledge(client *upstream.Client, seq uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.node(client).acknowledged = seq
+}
+
+func (b *upstreamBacklog) finishCatchUp(client *upstream.Client, lagging bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	node := b.node(client)
+	node.catchingUp = false
+	node.lagging = lagging
+}
+
+// node returns the state of `client`, it must be called with the lock held.
+func (b *upstreamBacklog) node(client *upstream.Client) *backlogNode {
+	node, found := b.nodes[client]
+	if !found {
+		node = &backlogNode{}
+		b.nodes[client] = node
+	}
+
+	return node
+}
+
+// callPayloadStatus performs a payload or forkchoice call against `client` and returns the
+// payload status it answered with.
+func callPayloadStatus(ctx context.Context, client *upstream.Client, method string, params []interface{}) (*PayloadStatusV1Args, error) {
+	ctx, cancel := context.WithTimeout(ctx, upstreamCallTimeout)
+	defer cancel()
+
+	content, err := client.DoRequest(ctx, method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(method, "engine_forkchoiceUpdated") {
+		reply := &ForkchoiceUpdatedV1Reply{}
+		if err := json.Unmarshal(content, reply); err != nil {
+			return nil, fmt.Errorf("decode %s reply: %w", method, err)
+		}
+
+		return &reply.PayloadStatus, nil
+	}
+
+	status := &PayloadStatusV1Args{}
+	if err := json.Unmarshal(content, status); err != nil {
+		return nil, fmt.Errorf("decode %s reply: %w", method, err)
+	}
+
+	return status, nil
+}
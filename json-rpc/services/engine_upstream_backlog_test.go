@@ -0,0 +1,98 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamBacklog_CatchUp(t *testing.T) {
+	var lock sync.Mutex
+	var replayed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		lock.Lock()
+		replayed = append(replayed, request.Method+":"+request.Params[0].(string))
+		lock.Unlock()
+
+		if request.Method == "engine_forkchoiceUpdatedV2" {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"payloadStatus":{"status":"VALID"},"payloadId":null}}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"status":"VALID"}}`))
+	}))
+	defer server.Close()
+
+	healthy := upstream.NewClient("http://healthy", nil)
+	lagging := upstream.NewClient(server.URL, nil)
+	valid := &PayloadStatusV1Args{Status: EnginePayloadStatusValid}
+	syncing := &PayloadStatusV1Args{Status: EnginePayloadStatusSyncing}
+
+	backlog := newUpstreamBacklog()
+
+	seq := backlog.Append("engine_newPayloadV2", []interface{}{"0x01"})
+	backlog.Track(seq, payloadStatusVotes{{upstream: healthy, status: valid}, {upstream: lagging, status: valid}})
+
+	seq = backlog.Append("engine_newPayloadV2", []interface{}{"0x02"})
+	backlog.Track(seq, payloadStatusVotes{{upstream: healthy, status: valid}, {upstream: lagging, err: assert.AnError}})
+
+	seq = backlog.Append("engine_forkchoiceUpdatedV2", []interface{}{"0x02", nil})
+	backlog.Track(seq, payloadStatusVotes{{upstream: healthy, status: valid}, {upstream: lagging, status: syncing}})
+
+	require.Eventually(t, func() bool {
+		backlog.lock.Lock()
+		defer backlog.lock.Unlock()
+
+		return !backlog.node(lagging).catchingUp
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, []string{"engine_newPayloadV2:0x02", "engine_forkchoiceUpdatedV2:0x02"}, replayed)
+	assert.Equal(t, seq, backlog.node(lagging).acknowledged)
+	assert.False(t, backlog.node(lagging).lagging)
+	assert.Equal(t, seq, backlog.node(healthy).acknowledged)
+}
+
+func TestUpstreamBacklog_Pending(t *testing.T) {
+	node := upstream.NewClient("http://a", nil)
+	backlog := newUpstreamBacklog()
+
+	for i := 0; i < backlogCapacity+10; i++ {
+		backlog.Append("engine_newPayloadV2", nil)
+	}
+
+	calls, truncated := backlog.pending(node)
+	assert.Len(t, calls, backlogCapacity)
+	assert.True(t, truncated)
+	assert.Equal(t, uint64(11), calls[0].seq)
+
+	backlog.acknowledge(node, uint64(backlogCapacity+5))
+	calls, truncated = backlog.pending(node)
+	assert.Len(t, calls, 5)
+	assert.False(t, truncated)
+}
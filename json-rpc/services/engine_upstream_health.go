@@ -0,0 +1,85 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+)
+
+type upstreamState uint8
+
+// The order of the states is the order in which upstreams are preferred, lower is better.
+const (
+	upstreamStateSynced upstreamState = iota
+	upstreamStateUnknown
+	upstreamStateSyncing
+	upstreamStateFailing
+)
+
+// upstreamHealth tracks the state of each upstream as observed from its answers to the
+// payload and forkchoice calls forwarded by the proxy.
+type upstreamHealth struct {
+	lock   sync.Mutex
+	states map[*upstream.Client]upstreamState
+}
+
+func newUpstreamHealth() *upstreamHealth {
+	return &upstreamHealth{
+		states: make(map[*upstream.Client]upstreamState),
+	}
+}
+
+// Record updates the state of `node` from the payload status it answered with or the
+// error it failed with.
+func (h *upstreamHealth) Record(node *upstream.Client, status *PayloadStatusV1Args, err error) {
+	state := upstreamStateSynced
+	switch {
+	case err != nil:
+		state = upstreamStateFailing
+	case status.Status == EnginePayloadStatusSyncing || status.Status == EnginePayloadStatusAccepted:
+		state = upstreamStateSyncing
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.states[node] = state
+}
+
+// Ranked returns a copy of `nodes` sorted from the healthiest to the least healthy upstream,
+// upstreams in the same state keep their configured order.
+func (h *upstreamHealth) Ranked(nodes []*upstream.Client) []*upstream.Client {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	stateOf := func(node *upstream.Client) upstreamState {
+		if state, found := h.states[node]; found {
+			return state
+		}
+
+		return upstreamStateUnknown
+	}
+
+	out := make([]*upstream.Client, len(nodes))
+	copy(out, nodes)
+	sort.SliceStable(out, func(i, j int) bool {
+		return stateOf(out[i]) < stateOf(out[j])
+	})
+
+	return out
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ethrpc "github.com/streamingfast/eth-go/rpc"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"go.uber.org/zap"
+)
+
+const (
+	// upstreamWatchInterval is the delay between two reachability probes of the upstreams
+	upstreamWatchInterval = 2 * time.Second
+
+	upstreamProbeTimeout = 2 * time.Second
+)
+
+// WatchUpstreams probes the upstreams until `ctx` is done. Each time an upstream becomes
+// reachable, at startup or after being down, it's immediately sent the last forkchoice state
+// so that it resumes following the chain without waiting for the next forkchoice update of
+// the consensus client. An upstream restarting between two probes is seen as a reachable one,
+// it resumes with the next forkchoice update of the consensus client. When `heads` is set,
+// it tracks the read endpoints of the upstreams in the same order and probing an upstream
+// refreshes the head of its read endpoint so a single loop polls the upstreams.
+func (e *EngineService) WatchUpstreams(ctx context.Context, heads *upstream.HeadTracker) {
+	reachable := make(map[*upstream.Client]bool, len(e.upstreams))
+
+	ticker := time.NewTicker(upstreamWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		for i, node := range e.upstreams {
+			var reader *upstream.Client
+			if heads != nil {
+				reader = heads.Upstreams()[i]
+			}
+
+			if !probeUpstream(ctx, node, reader, heads) {
+				if reachable[node] {
+					zlog.Info("upstream is unreachable", zap.Stringer("upstream", node))
+				}
+
+				reachable[node] = false
+				continue
+			}
+
+			if !reachable[node] {
+				// The upstream is tried again on next probe if it could not be resumed
+				reachable[node] = e.resumeForkchoice(ctx, node)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resumeForkchoice sends the last forkchoice state to `node` and returns false if the call
+// failed and should be retried.
+func (e *EngineService) resumeForkchoice(ctx context.Context, node *upstream.Client) bool {
+	method, state := e.forkchoice.Get()
+	if state == nil {
+		return true
+	}
+
+	status, err := callPayloadStatus(ctx, node, method, []interface{}{state, nil})
+	if err != nil {
+		zlog.Warn("unable to resume upstream with last forkchoice state", zap.Stringer("upstream", node), zap.String("method", method), zap.Error(err))
+		return false
+	}
+
+	e.health.Record(node, status, nil)
+	zlog.Info("resumed upstream with last forkchoice state",
+		zap.Stringer("upstream", node),
+		zap.String("method", method),
+		zap.Stringer("head_block_hash", state.HeadBlockHash),
+		zap.String("status", string(status.Status)),
+	)
+
+	return true
+}
+
+// probeUpstream returns true if `node` answers a JSON-RPC call, even with an error. When
+// `heads` is set, the head of `reader`, the read endpoint of `node`, is refreshed too, by the
+// probing call itself when `node` is its own read endpoint.
+func probeUpstream(ctx context.Context, node, reader *upstream.Client, heads *upstream.HeadTracker) bool {
+	var err error
+	if heads != nil && reader == node {
+		err = heads.Update(ctx, node)
+	} else {
+		if heads != nil {
+			// The tracker logs a read endpoint failing, the Engine API may still be reachable
+			heads.Update(ctx, reader)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, upstreamProbeTimeout)
+		defer cancel()
+
+		_, err = node.DoRequest(ctx, "eth_chainId", nil)
+	}
+
+	var errResponse *ethrpc.ErrResponse
+	return err == nil || errors.As(err, &errResponse)
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/geth-proxy/config"
+	"github.com/streamingfast/geth-proxy/json-rpc/enginetest"
+	"github.com/streamingfast/geth-proxy/json-rpc/services"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restartableEngine serves a fake engine that can be restarted, losing its in-memory state,
+// while keeping the same endpoint.
+type restartableEngine struct {
+	lock     sync.Mutex
+	engine   *enginetest.Engine
+	requests int
+}
+
+func (r *restartableEngine) current() *enginetest.Engine {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.engine
+}
+
+// restart replaces the engine with a fresh one, offline until the returned engine is set online.
+func (r *restartableEngine) restart() *enginetest.Engine {
+	engine := enginetest.NewEngine(1337)
+	engine.SetOffline(true)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.engine = engine
+	return engine
+}
+
+// requestCount returns the number of requests received so far, including while offline.
+func (r *restartableEngine) requestCount() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.requests
+}
+
+func (r *restartableEngine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	r.requests++
+	engine := r.engine
+	r.lock.Unlock()
+
+	engine.ServeHTTP(w, req)
+}
+
+func TestEngineService_WatchUpstreamsResumesRestartedUpstream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	chainConfig, err := config.NetworkNameToChainConfig("battlefield")
+	require.NoError(t, err)
+
+	node := &restartableEngine{engine: enginetest.NewEngine(1337)}
+	server := httptest.NewServer(node)
+	defer server.Close()
+	upstreams := []*upstream.Client{upstream.NewClient(server.URL, nil)}
+
+	service := services.NewEngineService(chainConfig, upstreams, upstreams[0], services.QuorumPolicyPrimary, nil, nil, "", nil)
+	heads := upstream.NewHeadTracker(upstreams)
+	go service.WatchUpstreams(ctx, heads)
+	require.Eventually(t, func() bool { return heads.Best() == upstreams[0] }, 5*time.Second, 10*time.Millisecond, "probing refreshes the heads")
+
+	genesis := node.current().Genesis().Hash
+	reply := &services.ForkchoiceUpdatedV1Reply{}
+	require.NoError(t, service.ForkchoiceUpdatedV1(httptest.NewRequest("POST", "/", nil), &services.ForkchoiceUpdatedV1Args{
+		ForkchoiceState: services.ForkchoiceStateV1Args{HeadBlockHash: genesis, SafeBlockHash: genesis, FinalizedBlockHash: genesis},
+	}, reply))
+	require.Equal(t, services.EnginePayloadStatusValid, reply.PayloadStatus.Status)
+
+	// The process goes down long enough to be seen unreachable by a probe, then comes back
+	restarted := node.restart()
+	requests := node.requestCount()
+	require.Eventually(t, func() bool { return node.requestCount() > requests }, 10*time.Second, 10*time.Millisecond)
+	restarted.SetOffline(false)
+
+	require.Eventually(t, func() bool { return restarted.CallCount("engine_forkchoiceUpdatedV1") == 1 }, 10*time.Second, 10*time.Millisecond)
+	calls := restarted.Calls()
+	assert.Equal(t, "engine_forkchoiceUpdatedV1", calls[len(calls)-1].Method)
+	assert.JSONEq(t, `{"headBlockHash":"`+genesis.Pretty()+`","safeBlockHash":"`+genesis.Pretty()+`","finalizedBlockHash":"`+genesis.Pretty()+`"}`, string(calls[len(calls)-1].Params[0]))
+}
+
+func TestEngineService_WatchUpstreamsRefreshesReadEndpoints(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	chainConfig, err := config.NetworkNameToChainConfig("battlefield")
+	require.NoError(t, err)
+
+	newUpstream := func(engine *enginetest.Engine) *upstream.Client {
+		server := httptest.NewServer(engine)
+		t.Cleanup(server.Close)
+
+		return upstream.NewClient(server.URL, nil)
+	}
+
+	engine := enginetest.NewEngine(1337)
+	reads := enginetest.NewEngine(1337)
+	upstreams := []*upstream.Client{newUpstream(engine)}
+	readers := []*upstream.Client{newUpstream(reads)}
+
+	service := services.NewEngineService(chainConfig, upstreams, upstreams[0], services.QuorumPolicyPrimary, nil, nil, "", nil)
+	heads := upstream.NewHeadTracker(readers)
+	go service.WatchUpstreams(ctx, heads)
+
+	require.Eventually(t, func() bool { return heads.Best() == readers[0] }, 5*time.Second, 10*time.Millisecond, "probing refreshes the read endpoint heads")
+	assert.Equal(t, 0, engine.CallCount("eth_blockNumber"), "heads are not read from the Engine API endpoint")
+	assert.NotZero(t, engine.CallCount("eth_chainId"), "the Engine API endpoint is probed")
+}
@@ -17,26 +17,13 @@ package services
 import (
 	"github.com/gorilla/rpc/v2"
 	"github.com/streamingfast/eth-go"
-	"go.uber.org/zap"
-	"net/http"
-
-	"github.com/streamingfast/logging"
 )
 
 type WithdrawalV1Args struct {
 	Index          eth.Uint64  `json:"index"`
 	ValidatorIndex eth.Uint64  `json:"validatorIndex"`
 	Address        eth.Address `json:"address"`
-	Amount         eth.Hex     `json:"amount"`
-}
-
-func (e *EngineService) WithdrawalV1(r *http.Request, args *WithdrawalV1Args, reply *eth.Hex) error {
-	ctx := r.Context()
-	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("engine withdrawal v1:", zap.Reflect("args", args))
-
-	*reply = eth.MustNewHex("ab")
-	return nil
+	Amount         eth.Uint64  `json:"amount"`
 }
 
 func (e *WithdrawalV1Args) Validate(requestInfo *rpc.RequestInfo) error {
@@ -16,12 +16,21 @@ package services
 
 import "github.com/streamingfast/geth-proxy/config"
 
+// EthService serves the few `eth` methods depending on the proxy's own state, `eth_chainId`,
+// `eth_subscribe` and `eth_unsubscribe`. Every other `eth` call is forwarded to an upstream.
 type EthService struct {
-	evmExecutor config.CallExecutor
+	chainConfig *config.ChainConfig
+
+	// subscriptions serves `eth_subscribe` on persistent connections, subscribing is
+	// rejected when nil.
+	subscriptions *Subscriptions
 }
 
-func NewEthService() *EthService {
-	return &EthService{}
+func NewEthService(chainConfig *config.ChainConfig, subscriptions *Subscriptions) *EthService {
+	return &EthService{
+		chainConfig:   chainConfig,
+		subscriptions: subscriptions,
+	}
 }
 
 func (e *EthService) Namespace() string {
@@ -31,7 +31,7 @@ func (e *EthService) ChainId(r *http.Request, args *ChainIdArgs, reply *eth.Uint
 	zlogger := logging.Logger(ctx, zlog)
 	zlogger.Info("chain Id:", zap.Reflect("args", args))
 
-	*reply = 5
+	*reply = eth.Uint64(e.chainConfig.ChainID.Uint64())
 	return nil
 }
 
@@ -0,0 +1,116 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	pbtrxstream "github.com/streamingfast/firehose-ethereum/types/pb/sf/ethereum/trxstream/v1"
+	"go.uber.org/zap"
+)
+
+// pendingTransactionsRetryDelay is the delay before reconnecting to the pending transactions
+// stream after it ended or failed
+const pendingTransactionsRetryDelay = 5 * time.Second
+
+// FollowPendingTransactions notifies `newPendingTransactions` subscribers of each transaction
+// received from `client`, the transaction stream served by the node manager's transaction
+// pool plugin, reconnecting to it until `ctx` is done.
+func (s *Subscriptions) FollowPendingTransactions(ctx context.Context, client pbtrxstream.TransactionStreamClient) {
+	for {
+		err := s.streamPendingTransactions(ctx, client)
+		if ctx.Err() != nil {
+			return
+		}
+
+		zlog.Warn("pending transactions stream ended, reconnecting", zap.Duration("retry_delay", pendingTransactionsRetryDelay), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pendingTransactionsRetryDelay):
+		}
+	}
+}
+
+func (s *Subscriptions) streamPendingTransactions(ctx context.Context, client pbtrxstream.TransactionStreamClient) error {
+	stream, err := client.Transactions(ctx, &pbtrxstream.TransactionRequest{})
+	if err != nil {
+		return err
+	}
+
+	zlog.Info("following pending transactions stream")
+	for {
+		trx, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		for _, sub := range s.subscribers(SubscriptionKindNewPendingTransactions) {
+			if sub.fullTransactions {
+				s.push(sub, newPendingTransaction(trx))
+			} else {
+				s.push(sub, hexutil.Bytes(trx.Hash))
+			}
+		}
+	}
+}
+
+// pendingTransaction is a pending transaction as notified by execution clients to
+// subscribers asking for full transactions.
+type pendingTransaction struct {
+	Hash     common.Hash     `json:"hash"`
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Input    hexutil.Bytes   `json:"input"`
+	V        *hexutil.Big    `json:"v"`
+	R        *hexutil.Big    `json:"r"`
+	S        *hexutil.Big    `json:"s"`
+}
+
+func newPendingTransaction(trx *pbtrxstream.Transaction) *pendingTransaction {
+	out := &pendingTransaction{
+		Hash:     common.BytesToHash(trx.Hash),
+		From:     common.BytesToAddress(trx.From),
+		Nonce:    hexutil.Uint64(trx.Nonce),
+		Gas:      hexutil.Uint64(trx.GasLimit),
+		GasPrice: (*hexutil.Big)(trx.GasPrice.Native()),
+		Value:    (*hexutil.Big)(trx.Value.Native()),
+		Input:    trx.Input,
+		V:        (*hexutil.Big)(new(big.Int).SetBytes(trx.V)),
+		R:        (*hexutil.Big)(new(big.Int).SetBytes(trx.R)),
+		S:        (*hexutil.Big)(new(big.Int).SetBytes(trx.S)),
+	}
+
+	// Contract creations have no recipient
+	if len(trx.To) > 0 {
+		to := common.BytesToAddress(trx.To)
+		out.To = &to
+	}
+
+	return out
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
+)
+
+type SubscribeArgs struct {
+	Kind SubscriptionKind `json:"kind"`
+
+	// Options is the logs filter of a `logs` subscription or whether full transactions are
+	// notified for a `newPendingTransactions` subscription
+	Options json.RawMessage `json:"options"`
+}
+
+func (e *EthService) Subscribe(r *http.Request, args *SubscribeArgs, reply *string) error {
+	ctx := r.Context()
+	zlogger := logging.Logger(ctx, zlog)
+	zlogger.Debug("eth subscribe", zap.String("kind", string(args.Kind)), zap.ByteString("options", args.Options))
+
+	if e.subscriptions == nil {
+		return &json2.Error{Code: json2.E_NO_METHOD, Message: "subscriptions are not enabled"}
+	}
+
+	notifier := notifierFromContext(ctx)
+	if notifier == nil {
+		return &json2.Error{Code: json2.E_NO_METHOD, Message: "notifications not supported"}
+	}
+
+	sub := newSubscription(args.Kind)
+	if err := sub.configure(args.Options); err != nil {
+		return &json2.Error{Code: json2.E_BAD_PARAMS, Message: err.Error()}
+	}
+
+	e.subscriptions.subscribe(ctx, notifier, sub)
+
+	*reply = sub.id
+	return nil
+}
+
+func (s *subscription) configure(options json.RawMessage) (err error) {
+	hasOptions := len(options) > 0 && string(options) != "null"
+
+	switch s.kind {
+	case SubscriptionKindNewHeads:
+		if hasOptions {
+			return fmt.Errorf("%s subscription accepts no options", s.kind)
+		}
+
+	case SubscriptionKindLogs:
+		s.filter, err = parseLogFilter(options)
+
+	case SubscriptionKindNewPendingTransactions:
+		if hasOptions {
+			if err := json.Unmarshal(options, &s.fullTransactions); err != nil {
+				return fmt.Errorf("%s subscription option must be a boolean", s.kind)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported subscription kind %q", s.kind)
+	}
+
+	return err
+}
+
+func (a *SubscribeArgs) Validate(requestInfo *rpc.RequestInfo) error {
+	return nil
+}
+
+type UnsubscribeArgs struct {
+	ID string `json:"id"`
+}
+
+func (e *EthService) Unsubscribe(r *http.Request, args *UnsubscribeArgs, reply *bool) error {
+	logging.Logger(r.Context(), zlog).Debug("eth unsubscribe", zap.String("id", args.ID))
+
+	if e.subscriptions == nil {
+		return &json2.Error{Code: json2.E_NO_METHOD, Message: "subscriptions are not enabled"}
+	}
+
+	*reply = e.subscriptions.unsubscribe(args.ID)
+	return nil
+}
+
+func (a *UnsubscribeArgs) Validate(requestInfo *rpc.RequestInfo) error {
+	return nil
+}
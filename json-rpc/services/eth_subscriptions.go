@@ -0,0 +1,307 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/streamingfast/eth-go"
+	"github.com/streamingfast/geth-proxy/json-rpc/upstream"
+	"github.com/streamingfast/logging"
+	"github.com/tidwall/gjson"
+	"go.uber.org/zap"
+)
+
+var errNoUpstreamResult = errors.New("no upstream returned a result")
+
+// subscriptionQueueSize is the number of notifications buffered for a single subscription,
+// a subscriber lagging more than that is considered gone and its subscription is dropped
+const subscriptionQueueSize = 1024
+
+type SubscriptionKind string
+
+const (
+	SubscriptionKindNewHeads               SubscriptionKind = "newHeads"
+	SubscriptionKindLogs                   SubscriptionKind = "logs"
+	SubscriptionKindNewPendingTransactions SubscriptionKind = "newPendingTransactions"
+)
+
+// Notifier pushes notifications to the client of a persistent connection, it's found in the
+// context of requests received on such connection, see WithNotifier.
+type Notifier interface {
+	// Notify sends a JSON-RPC notification, it blocks until the response of the request the
+	// notifier was created for has been sent, so a subscription id is always known by the
+	// client before its first notification.
+	Notify(method string, params interface{}) error
+}
+
+type notifierKey struct{}
+
+func WithNotifier(ctx context.Context, notifier Notifier) context.Context {
+	return context.WithValue(ctx, notifierKey{}, notifier)
+}
+
+func notifierFromContext(ctx context.Context) Notifier {
+	notifier, _ := ctx.Value(notifierKey{}).(Notifier)
+	return notifier
+}
+
+// Subscriptions dispatches the events seen by the proxy to the `eth_subscribe` subscribers.
+// Heads come from the forkchoice updates accepted by the upstreams, see HeadUpdated, and
+// pending transactions from a transaction stream, see FollowPendingTransactions. Headers
+// and receipts of new heads are fetched from the first upstream able to serve them.
+//
+// Logs removed by a chain reorganization are not notified.
+type Subscriptions struct {
+	upstreams []*upstream.Client
+	heads     chan eth.Hash
+
+	lock          sync.Mutex
+	subscriptions map[string]*subscription
+}
+
+func NewSubscriptions(upstreams []*upstream.Client) *Subscriptions {
+	return &Subscriptions{
+		upstreams:     upstreams,
+		heads:         make(chan eth.Hash, 16),
+		subscriptions: make(map[string]*subscription),
+	}
+}
+
+type subscription struct {
+	id     string
+	kind   SubscriptionKind
+	filter *logFilter
+
+	// fullTransactions notifies full pending transactions instead of their hash
+	fullTransactions bool
+
+	queue chan interface{}
+	done  chan struct{}
+}
+
+// HeadUpdated is called each time a forkchoice state is accepted as VALID, it never blocks.
+func (s *Subscriptions) HeadUpdated(head eth.Hash) {
+	select {
+	case s.heads <- head:
+	default:
+		zlog.Warn("head notifications are lagging, dropping head", zap.Stringer("head", head))
+	}
+}
+
+// Run publishes heads given to HeadUpdated until `ctx` is done.
+func (s *Subscriptions) Run(ctx context.Context) {
+	var lastHead eth.Hash
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case head := <-s.heads:
+			if bytes.Equal(head, lastHead) {
+				continue
+			}
+
+			lastHead = head
+			s.publishHead(ctx, head)
+		}
+	}
+}
+
+func (s *Subscriptions) publishHead(ctx context.Context, head eth.Hash) {
+	headSubscribers := s.subscribers(SubscriptionKindNewHeads)
+	logsSubscribers := s.subscribers(SubscriptionKindLogs)
+	if len(headSubscribers) == 0 && len(logsSubscribers) == 0 {
+		return
+	}
+
+	if len(headSubscribers) > 0 {
+		header, err := s.fetchHeader(ctx, head)
+		if err != nil {
+			zlog.Warn("unable to fetch new head header", zap.Stringer("head", head), zap.Error(err))
+		} else {
+			for _, sub := range headSubscribers {
+				s.push(sub, header)
+			}
+		}
+	}
+
+	if len(logsSubscribers) > 0 {
+		logs, err := s.fetchLogs(ctx, head)
+		if err != nil {
+			zlog.Warn("unable to fetch new head receipts", zap.Stringer("head", head), zap.Error(err))
+			return
+		}
+
+		for _, log := range logs {
+			for _, sub := range logsSubscribers {
+				if sub.filter.Matches(log) {
+					s.push(sub, log)
+				}
+			}
+		}
+	}
+}
+
+// fetchHeader returns the header of block `hash` as notified by execution clients, the
+// block without its transactions, uncles and size.
+func (s *Subscriptions) fetchHeader(ctx context.Context, hash eth.Hash) (json.RawMessage, error) {
+	block, err := s.fetch(ctx, "eth_getBlockByHash", hash, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(block, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, field := range []string{"transactions", "uncles", "withdrawals", "size", "totalDifficulty"} {
+		delete(fields, field)
+	}
+
+	return json.Marshal(fields)
+}
+
+// fetchLogs returns all logs of block `hash`, in order, extracted from the block receipts.
+func (s *Subscriptions) fetchLogs(ctx context.Context, hash eth.Hash) (out []json.RawMessage, err error) {
+	receipts, err := s.fetch(ctx, "eth_getBlockReceipts", hash)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, logs := range gjson.GetBytes(receipts, "#.logs").Array() {
+		for _, log := range logs.Array() {
+			out = append(out, json.RawMessage(log.Raw))
+		}
+	}
+
+	return out, nil
+}
+
+// fetch performs the call on each upstream in turn until one of them returns a non-null
+// result, upstreams may not know about the block yet.
+func (s *Subscriptions) fetch(ctx context.Context, method string, params ...interface{}) (result json.RawMessage, err error) {
+	err = errNoUpstreamResult
+	for _, node := range s.upstreams {
+		callCtx, cancel := context.WithTimeout(ctx, upstreamCallTimeout)
+		result, err = node.DoRequest(callCtx, method, params)
+		cancel()
+
+		if err != nil {
+			logging.Logger(ctx, zlog).Debug("upstream call failed, trying next one", zap.String("method", method), zap.Stringer("upstream", node), zap.Error(err))
+			continue
+		}
+
+		if string(result) == "null" {
+			err = errNoUpstreamResult
+			continue
+		}
+
+		return result, nil
+	}
+
+	return nil, err
+}
+
+func (s *Subscriptions) subscribe(ctx context.Context, notifier Notifier, sub *subscription) {
+	s.lock.Lock()
+	s.subscriptions[sub.id] = sub
+	s.lock.Unlock()
+
+	zlogger := logging.Logger(ctx, zlog)
+	zlogger.Debug("subscription created", zap.String("id", sub.id), zap.String("kind", string(sub.kind)))
+
+	go func() {
+		defer s.unsubscribe(sub.id)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.done:
+				return
+			case result := <-sub.queue:
+				err := notifier.Notify("eth_subscription", &subscriptionNotification{Subscription: sub.id, Result: result})
+				if err != nil {
+					zlogger.Debug("unable to notify subscriber, dropping subscription", zap.String("id", sub.id), zap.Error(err))
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (s *Subscriptions) unsubscribe(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sub, found := s.subscriptions[id]
+	if !found {
+		return false
+	}
+
+	delete(s.subscriptions, id)
+	close(sub.done)
+
+	return true
+}
+
+func (s *Subscriptions) subscribers(kind SubscriptionKind) (out []*subscription) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, sub := range s.subscriptions {
+		if sub.kind == kind {
+			out = append(out, sub)
+		}
+	}
+
+	return out
+}
+
+func (s *Subscriptions) push(sub *subscription, result interface{}) {
+	select {
+	case sub.queue <- result:
+	default:
+		zlog.Info("subscriber is too slow, dropping subscription", zap.String("id", sub.id), zap.String("kind", string(sub.kind)))
+		s.unsubscribe(sub.id)
+	}
+}
+
+type subscriptionNotification struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+func newSubscription(kind SubscriptionKind) *subscription {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		// Reading from the system random source never fails on supported platforms
+		panic(err)
+	}
+
+	return &subscription{
+		id:    "0x" + hex.EncodeToString(id),
+		kind:  kind,
+		queue: make(chan interface{}, subscriptionQueueSize),
+		done:  make(chan struct{}),
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// logFilter selects the logs notified to a `logs` subscription, a log matches when it was
+// emitted by one of the addresses and each of its topics is one of the topics accepted at
+// that position. No addresses or no topics at a position accepts anything.
+type logFilter struct {
+	addresses map[string]bool
+	topics    []map[string]bool
+}
+
+// logFilterArgs is the filter object accepted by `eth_subscribe("logs", filter)`, `address`
+// is either a single address or a list of addresses and each entry of `topics` is either
+// null, a single topic or a list of topics.
+type logFilterArgs struct {
+	Address json.RawMessage   `json:"address"`
+	Topics  []json.RawMessage `json:"topics"`
+}
+
+func parseLogFilter(raw json.RawMessage) (*logFilter, error) {
+	filter := &logFilter{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return filter, nil
+	}
+
+	args := &logFilterArgs{}
+	if err := json.Unmarshal(raw, args); err != nil {
+		return nil, fmt.Errorf("invalid logs filter: %w", err)
+	}
+
+	var err error
+	if filter.addresses, err = parseHexSet(args.Address, 20); err != nil {
+		return nil, fmt.Errorf("invalid address: %w", err)
+	}
+
+	for i, topics := range args.Topics {
+		set, err := parseHexSet(topics, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic %d: %w", i, err)
+		}
+
+		filter.topics = append(filter.topics, set)
+	}
+
+	return filter, nil
+}
+
+// parseHexSet parses either null, a single hex value or a list of hex values of `size`
+// bytes, returning a nil set when no value is given.
+func parseHexSet(raw json.RawMessage, size int) (map[string]bool, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var values []string
+	if err := json.Unmarshal(raw, &values); err != nil {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("expected a hex string or a list of hex strings, got %s", string(raw))
+		}
+
+		values = []string{value}
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		normalized := strings.ToLower(value)
+		if !strings.HasPrefix(normalized, "0x") || len(normalized) != 2+2*size {
+			return nil, fmt.Errorf("%q is not a %d bytes hex value", value, size)
+		}
+
+		set[normalized] = true
+	}
+
+	return set, nil
+}
+
+// Matches returns true if `log`, a log object as returned in receipts, is selected by the filter.
+func (f *logFilter) Matches(log json.RawMessage) bool {
+	if len(f.addresses) > 0 && !f.addresses[strings.ToLower(gjson.GetBytes(log, "address").String())] {
+		return false
+	}
+
+	logTopics := gjson.GetBytes(log, "topics").Array()
+	if len(f.topics) > len(logTopics) {
+		return false
+	}
+
+	for i, accepted := range f.topics {
+		if len(accepted) > 0 && !accepted[strings.ToLower(logTopics[i].String())] {
+			return false
+		}
+	}
+
+	return true
+}
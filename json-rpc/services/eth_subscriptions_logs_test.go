@@ -0,0 +1,67 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogFilter_Matches(t *testing.T) {
+	log := json.RawMessage(`{
+		"address": "0xA94F5374FCE5EDBC8E2A8697C15331677E6EBF0B",
+		"topics": [
+			"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+			"0x0000000000000000000000000000000000000000000000000000000000000001"
+		]
+	}`)
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected bool
+	}{
+		{"no filter", `null`, true},
+		{"empty filter", `{}`, true},
+		{"single address", `{"address":"0xa94f5374fce5edbc8e2a8697c15331677e6ebf0b"}`, true},
+		{"address list", `{"address":["0x0000000000000000000000000000000000000001","0xa94f5374fce5edbc8e2a8697c15331677e6ebf0b"]}`, true},
+		{"other address", `{"address":"0x0000000000000000000000000000000000000001"}`, false},
+		{"first topic", `{"topics":["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"]}`, true},
+		{"wildcard then topic", `{"topics":[null,"0x0000000000000000000000000000000000000000000000000000000000000001"]}`, true},
+		{"topic alternatives", `{"topics":[["0x0000000000000000000000000000000000000000000000000000000000000002","0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"]]}`, true},
+		{"other topic", `{"topics":[null,"0x0000000000000000000000000000000000000000000000000000000000000002"]}`, false},
+		{"more topics than log", `{"topics":[null,null,null]}`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := parseLogFilter(json.RawMessage(test.filter))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expected, filter.Matches(log))
+		})
+	}
+}
+
+func TestParseLogFilter_Invalid(t *testing.T) {
+	_, err := parseLogFilter(json.RawMessage(`{"address":"0x01"}`))
+	assert.EqualError(t, err, `invalid address: "0x01" is not a 20 bytes hex value`)
+
+	_, err = parseLogFilter(json.RawMessage(`{"topics":[1]}`))
+	assert.EqualError(t, err, `invalid topic 0: expected a hex string or a list of hex strings, got 1`)
+}
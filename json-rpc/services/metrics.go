@@ -0,0 +1,25 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"github.com/streamingfast/dmetrics"
+)
+
+var MetricsSet = dmetrics.NewSet()
+
+var TransitionConfigurationMismatchCount = MetricsSet.NewCounterVec("transition_configuration_mismatch_count", []string{"source"}, "Number of engine_exchangeTransitionConfigurationV1 calls where the consensus client or an upstream disagreed with the proxy configuration")
+
+var ExecutionDivergenceCount = MetricsSet.NewCounterVec("execution_divergence_count", []string{"method"}, "Number of payloads for which an upstream answered VALID while another answered INVALID")
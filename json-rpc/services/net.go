@@ -0,0 +1,33 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import "github.com/streamingfast/geth-proxy/config"
+
+// NetService serves `net_version` from the configured network, every other `net` call is
+// forwarded to an upstream.
+type NetService struct {
+	chainConfig *config.ChainConfig
+}
+
+func NewNetService(chainConfig *config.ChainConfig) *NetService {
+	return &NetService{
+		chainConfig: chainConfig,
+	}
+}
+
+func (n *NetService) Namespace() string {
+	return "net"
+}
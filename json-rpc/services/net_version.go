@@ -15,32 +15,22 @@
 package services
 
 import (
-	"github.com/gorilla/rpc/v2"
-	"go.uber.org/zap"
 	"net/http"
 
-	"github.com/streamingfast/eth-go"
+	"github.com/gorilla/rpc/v2"
 	"github.com/streamingfast/logging"
 )
 
-type SyncingArgs struct {
-}
-
-type SyncingValidResp struct {
-	startingBlock eth.Uint64
-	currentBlock  eth.Uint64
-	highestBlock  eth.Uint64
+type VersionArgs struct {
 }
 
-func (e *EthService) Syncing(r *http.Request, args *SyncingArgs, reply *SyncingValidResp) error {
-	ctx := r.Context()
-	zlogger := logging.Logger(ctx, zlog)
-	zlogger.Info("Syncing args:", zap.Reflect("args", args))
-	zlogger.Info("Syncing reply:", zap.Reflect("reply", reply))
+func (n *NetService) Version(r *http.Request, args *VersionArgs, reply *NetworkID) error {
+	logging.Logger(r.Context(), zlog).Debug("net version")
 
+	*reply = NetworkID(n.chainConfig.NetworkID)
 	return nil
 }
 
-func (e *SyncingArgs) Validate(requestInfo *rpc.RequestInfo) error {
+func (a *VersionArgs) Validate(requestInfo *rpc.RequestInfo) error {
 	return nil
 }
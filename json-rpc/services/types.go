@@ -15,8 +15,10 @@
 package services
 
 import (
+	"fmt"
 	"math/big"
 	"strconv"
+	"strings"
 )
 
 // ServiceHandler is an abstraction that all of our Ethereum JSON-RPC handler implements
@@ -30,6 +32,34 @@ type ServiceHandler interface {
 
 type BigInt big.Int
 
+// UnmarshalText decodes a JSON-RPC quantity, an hex encoded number prefixed with `0x`,
+// also accepting plain decimal value for convenience.
+func (b *BigInt) UnmarshalText(text []byte) error {
+	in := string(text)
+
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(in, "0x"), 16)
+	if !strings.HasPrefix(in, "0x") {
+		value, ok = new(big.Int).SetString(in, 10)
+	}
+
+	if !ok {
+		return fmt.Errorf("invalid big int quantity %q", in)
+	}
+
+	*b = BigInt(*value)
+	return nil
+}
+
+// MarshalJSONRPC serializes the value as a JSON-RPC quantity, `rpc.MarshalJSONRPC` only knows
+// about `*big.Int` and would otherwise serialize the internal fields of the struct.
+func (b *BigInt) MarshalJSONRPC() ([]byte, error) {
+	return []byte(`"0x` + b.Int().Text(16) + `"`), nil
+}
+
+func (b *BigInt) Int() *big.Int {
+	return (*big.Int)(b)
+}
+
 type NetworkID uint64
 
 // MarshalJSONRPC ensures we serialize using the networkd id in `string` type just like
@@ -0,0 +1,159 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	ethrpc "github.com/streamingfast/eth-go/rpc"
+	"github.com/streamingfast/geth-proxy/json-rpc/jwtauth"
+	"github.com/tidwall/gjson"
+)
+
+// Client is an upstream execution node the proxy forwards calls to. When created with a
+// JWT secret, every request carries a freshly minted token as required by the
+// authenticated Engine API port of execution clients.
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+func NewClient(endpoint string, jwtSecret []byte) *Client {
+	httpClient := http.DefaultClient
+	if len(jwtSecret) > 0 {
+		httpClient = &http.Client{
+			Transport: &jwtRoundTripper{secret: jwtSecret, next: http.DefaultTransport},
+		}
+	}
+
+	return &Client{
+		endpoint:   endpoint,
+		httpClient: httpClient,
+	}
+}
+
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// DoRequest performs the JSON-RPC call and returns the raw JSON `result` value. When the
+// upstream answers with a JSON-RPC error, it's returned as an `*ethrpc.ErrResponse`.
+func (c *Client) DoRequest(ctx context.Context, method string, params []interface{}) (json.RawMessage, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := ethrpc.MarshalJSONRPC(&request{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s request: %w", method, err)
+	}
+
+	response, err := c.post(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := gjson.ParseBytes(response)
+	if rpcError := parsed.Get("error"); rpcError.Exists() && rpcError.Type != gjson.Null {
+		errResponse := &ethrpc.ErrResponse{}
+		if err := json.Unmarshal([]byte(rpcError.Raw), errResponse); err != nil {
+			return nil, fmt.Errorf("invalid %s error response %q: %w", method, rpcError.Raw, err)
+		}
+
+		return nil, errResponse
+	}
+
+	result := parsed.Get("result")
+	if !result.Exists() {
+		return nil, fmt.Errorf("invalid %s response, no result nor error: %q", method, string(response))
+	}
+
+	return json.RawMessage(result.Raw), nil
+}
+
+// Call performs the JSON-RPC call and decodes the `result` value into `out`.
+func (c *Client) Call(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	result, err := c.DoRequest(ctx, method, params)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(result, out); err != nil {
+		return fmt.Errorf("decode %s result: %w", method, err)
+	}
+
+	return nil
+}
+
+// Forward sends `body`, a JSON-RPC request or batch, as is and returns the raw response.
+func (c *Client) Forward(ctx context.Context, body []byte) ([]byte, error) {
+	return c.post(ctx, body)
+}
+
+func (c *Client) post(ctx context.Context, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request to %s: %w", c.endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("upstream %s responded with status %d: %s", c.endpoint, resp.StatusCode, string(content))
+	}
+
+	return content, nil
+}
+
+func (c *Client) String() string {
+	return c.endpoint
+}
+
+type jwtRoundTripper struct {
+	secret []byte
+	next   http.RoundTripper
+}
+
+func (t *jwtRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := jwtauth.NewToken(t.secret, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("mint jwt token: %w", err)
+	}
+
+	// A RoundTripper must not modify the original request
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	return t.next.RoundTrip(r)
+}
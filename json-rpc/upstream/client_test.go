@@ -0,0 +1,62 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streamingfast/geth-proxy/json-rpc/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SignsRequests(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, secret)
+
+	var result string
+	require.NoError(t, client.Call(context.Background(), "eth_chainId", nil, &result))
+	assert.Equal(t, "0x1", result)
+
+	require.Len(t, tokens, 1)
+	assert.NoError(t, jwtauth.ValidateToken(secret, tokens[0], time.Now()))
+}
+
+func TestClient_NoSecret(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL, nil).DoRequest(context.Background(), "eth_chainId", nil)
+	require.NoError(t, err)
+	assert.Empty(t, authorization)
+}
@@ -0,0 +1,129 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upstream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/streamingfast/eth-go"
+	"go.uber.org/zap"
+)
+
+const headPollTimeout = 2 * time.Second
+
+// HeadTracker keeps the head block number and sync status of the upstreams' read endpoints, so
+// read calls can be sent to the upstream that is the most advanced in the chain. It doesn't
+// poll on its own, the upstreams are refreshed by the loop already probing them, see Update.
+type HeadTracker struct {
+	upstreams []*Client
+
+	lock  sync.Mutex
+	heads map[*Client]*head
+}
+
+type head struct {
+	number  uint64
+	syncing bool
+}
+
+func NewHeadTracker(upstreams []*Client) *HeadTracker {
+	return &HeadTracker{
+		upstreams: upstreams,
+		heads:     make(map[*Client]*head),
+	}
+}
+
+// Upstreams returns the tracked upstreams, in configuration order.
+func (t *HeadTracker) Upstreams() []*Client {
+	return t.upstreams
+}
+
+// Poll refreshes the head of every upstream, concurrently.
+func (t *HeadTracker) Poll(ctx context.Context) {
+	wg := sync.WaitGroup{}
+	wg.Add(len(t.upstreams))
+
+	for _, node := range t.upstreams {
+		go func(node *Client) {
+			defer wg.Done()
+			t.Update(ctx, node)
+		}(node)
+	}
+
+	wg.Wait()
+}
+
+// Update refreshes the head of `node` and returns the error of the upstream call if it
+// failed, an upstream failing to answer is forgotten until it answers again.
+func (t *HeadTracker) Update(ctx context.Context, node *Client) error {
+	state, err := t.fetchHead(ctx, node)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if err != nil {
+		if _, known := t.heads[node]; known {
+			zlog.Info("upstream head is unknown, upstream is no longer selected for read calls", zap.Stringer("upstream", node), zap.Error(err))
+		}
+
+		delete(t.heads, node)
+		return err
+	}
+
+	t.heads[node] = state
+	return nil
+}
+
+func (t *HeadTracker) fetchHead(ctx context.Context, node *Client) (*head, error) {
+	ctx, cancel := context.WithTimeout(ctx, headPollTimeout)
+	defer cancel()
+
+	// `eth_syncing` is either `false` or an object describing the sync progress
+	var syncing interface{}
+	if err := node.Call(ctx, "eth_syncing", nil, &syncing); err != nil {
+		return nil, err
+	}
+
+	var number eth.Uint64
+	if err := node.Call(ctx, "eth_blockNumber", nil, &number); err != nil {
+		return nil, err
+	}
+
+	return &head{number: uint64(number), syncing: syncing != false}, nil
+}
+
+// Best returns the upstream with the highest head among those that are not syncing, the
+// first one in configuration order on ties, or nil when there is none.
+func (t *HeadTracker) Best() *Client {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var best *Client
+	var bestHead *head
+	for _, node := range t.upstreams {
+		state, found := t.heads[node]
+		if !found || state.syncing {
+			continue
+		}
+
+		if bestHead == nil || state.number > bestHead.number {
+			best, bestHead = node, state
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestHeadTracker_Best(t *testing.T) {
+	newNode := func(syncing string, blockNumber *string) *Client {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+
+			switch gjson.GetBytes(body, "method").String() {
+			case "eth_syncing":
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":%s}`, syncing)
+			case "eth_blockNumber":
+				if *blockNumber == "" {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+					return
+				}
+
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"%s"}`, *blockNumber)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		return NewClient(server.URL, nil)
+	}
+
+	syncingHead, lowHead, highHead := "0x100", "0x50", "0x60"
+	syncing := newNode(`{"startingBlock":"0x0","currentBlock":"0x100","highestBlock":"0x200"}`, &syncingHead)
+	low := newNode("false", &lowHead)
+	high := newNode("false", &highHead)
+
+	tracker := NewHeadTracker([]*Client{syncing, low, high})
+	assert.Nil(t, tracker.Best(), "not polled yet")
+
+	tracker.Poll(context.Background())
+	assert.Equal(t, high, tracker.Best())
+
+	highHead = ""
+	tracker.Poll(context.Background())
+	assert.Equal(t, low, tracker.Best(), "failing upstream is not selected")
+
+	lowHead = ""
+	tracker.Poll(context.Background())
+	assert.Nil(t, tracker.Best(), "only a syncing upstream left")
+}
@@ -0,0 +1,203 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/streamingfast/geth-proxy/json-rpc/jwtauth"
+	"github.com/streamingfast/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// websocketMaxMessageSize is the biggest message accepted from a client, large enough for
+	// Engine API payloads carrying blobs
+	websocketMaxMessageSize = 32 * 1024 * 1024
+
+	websocketPingInterval = 30 * time.Second
+	websocketWriteTimeout = 10 * time.Second
+)
+
+// websocketHandler upgrades HTTP requests to WebSocket connections and serves each JSON-RPC
+// message received on them through `next`, as if it was the body of an HTTP POST request
+// made on the upgraded request's path, see rpcConnection.
+//
+// Engine API calls are only accepted on connections whose upgrade request carried a valid
+// JWT bearer token, when a secret is configured.
+type websocketHandler struct {
+	next      http.Handler
+	jwtSecret []byte
+	upgrader  websocket.Upgrader
+
+	lock        sync.Mutex
+	connections map[*websocketConnection]bool
+}
+
+func newWebsocketHandler(next http.Handler, jwtSecret []byte) *websocketHandler {
+	return &websocketHandler{
+		next:        next,
+		jwtSecret:   jwtSecret,
+		connections: map[*websocketConnection]bool{},
+	}
+}
+
+func (h *websocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Logger(r.Context(), zlog)
+
+	authenticated := true
+	if len(h.jwtSecret) > 0 {
+		authenticated = h.authenticate(r)
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already replied with an HTTP error
+		logger.Debug("unable to upgrade to websocket", zap.Error(err))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	connection := &websocketConnection{
+		rpcConnection: rpcConnection{
+			next:          h.next,
+			request:       r.WithContext(ctx),
+			authenticated: authenticated,
+		},
+		conn:   conn,
+		cancel: cancel,
+	}
+
+	h.track(connection, true)
+	defer h.track(connection, false)
+
+	logger.Debug("websocket connection opened", zap.Bool("authenticated", authenticated))
+	connection.serve()
+	logger.Debug("websocket connection closed")
+}
+
+func (h *websocketHandler) authenticate(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return false
+	}
+
+	if err := jwtauth.ValidateToken(h.jwtSecret, token, time.Now()); err != nil {
+		logging.Logger(r.Context(), zlog).Info("websocket connection token is invalid, engine calls will be rejected", zap.Error(err))
+		return false
+	}
+
+	return true
+}
+
+func (h *websocketHandler) track(connection *websocketConnection, active bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if active {
+		h.connections[connection] = true
+	} else {
+		delete(h.connections, connection)
+	}
+}
+
+// Close closes all active connections, they are hijacked so they are not closed when the
+// HTTP server shuts down.
+func (h *websocketHandler) Close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for connection := range h.connections {
+		connection.close()
+	}
+}
+
+type websocketConnection struct {
+	rpcConnection
+
+	conn   *websocket.Conn
+	cancel context.CancelFunc
+
+	writeLock sync.Mutex
+}
+
+func (c *websocketConnection) serve() {
+	defer c.close()
+
+	c.conn.SetReadLimit(websocketMaxMessageSize)
+	go c.keepAlive()
+
+	c.send = func(message []byte) error {
+		return c.write(websocket.TextMessage, message)
+	}
+
+	err := c.rpcConnection.serve(c.read)
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		logging.Logger(c.request.Context(), zlog).Debug("websocket read failed", zap.Error(err))
+	}
+}
+
+func (c *websocketConnection) read() ([]byte, error) {
+	for {
+		messageType, message, err := c.conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		if messageType == websocket.TextMessage || messageType == websocket.BinaryMessage {
+			return message, nil
+		}
+	}
+}
+
+func (c *websocketConnection) keepAlive() {
+	ticker := time.NewTicker(websocketPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.request.Context().Done():
+			return
+		case <-ticker.C:
+			if err := c.write(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+func (c *websocketConnection) write(messageType int, data []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	err := c.conn.WriteMessage(messageType, data)
+	if err != nil {
+		logging.Logger(c.request.Context(), zlog).Debug("websocket write failed", zap.Error(err))
+	}
+
+	return err
+}
+
+func (c *websocketConnection) close() {
+	c.cancel()
+	c.conn.Close()
+}
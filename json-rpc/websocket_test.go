@@ -0,0 +1,85 @@
+// Copyright 2021 dfuse Platform Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonrpc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/streamingfast/geth-proxy/json-rpc/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestWebsocketHandler(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	// Echoes the method of the call as its result, calls without id are notifications
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		call := gjson.ParseBytes(body)
+		if !call.Get("id").Exists() {
+			return
+		}
+
+		w.Write([]byte(`{"jsonrpc":"2.0","result":"` + r.Method + " " + call.Get("method").String() + `","id":` + call.Get("id").Raw + `}`))
+	})
+
+	handler := newWebsocketHandler(next, secret)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	defer handler.Close()
+
+	dial := func(header http.Header) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+		require.NoError(t, err)
+
+		return conn
+	}
+
+	call := func(conn *websocket.Conn, message string) string {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(message)))
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, response, err := conn.ReadMessage()
+		require.NoError(t, err)
+
+		return string(response)
+	}
+
+	anonymous := dial(nil)
+	defer anonymous.Close()
+
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"POST eth_chainId","id":1}`, call(anonymous, `{"jsonrpc":"2.0","method":"eth_chainId","id":1}`))
+	assert.Equal(t, `{"error":{"code":-32600,"message":"engine calls require a connection authenticated with a valid token"},"id":2,"jsonrpc":"2.0"}`, call(anonymous, `{"jsonrpc":"2.0","method":"engine_exchangeCapabilities","id":2}`))
+
+	// Notifications have no response, the next response read is the one of the following call
+	require.NoError(t, anonymous.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_chainId"}`)))
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"POST eth_chainId","id":3}`, call(anonymous, `{"jsonrpc":"2.0","method":"eth_chainId","id":3}`))
+
+	token, err := jwtauth.NewToken(secret, time.Now())
+	require.NoError(t, err)
+
+	authenticated := dial(http.Header{"Authorization": []string{"Bearer " + token}})
+	defer authenticated.Close()
+
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"POST engine_exchangeCapabilities","id":4}`, call(authenticated, `{"jsonrpc":"2.0","method":"engine_exchangeCapabilities","id":4}`))
+}